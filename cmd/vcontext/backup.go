@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"vcontext/internal/backup"
+)
+
+const passphraseEnv = "VCONTEXT_BACKUP_PASSPHRASE"
+
+func runBackup(logger *slog.Logger, args []string) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	cf := newFormatFlags("backup", "backup [flags] [path]", "table", "json")
+	gzipped := cf.fs.Bool("gzip", cfg.Backup.Gzip, "compress the backup (implied by a .gz path)")
+	encrypt := cf.fs.Bool("encrypt", cfg.Backup.PassphraseFile != "", "encrypt the backup with a passphrase")
+	passphraseFile := cf.fs.String("passphrase-file", cfg.Backup.PassphraseFile, "read the passphrase from this file instead of $"+passphraseEnv)
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) > 1 {
+		return cf.usageFailed("backup takes at most one path")
+	}
+
+	opts := backup.Options{Gzip: *gzipped}
+	if *encrypt {
+		if opts.Passphrase, err = passphrase(*passphraseFile); err != nil {
+			return cf.usageFailed(err.Error())
+		}
+	}
+
+	// Without a path the backup goes to the scheduled backup directory and
+	// is rotated like scheduled ones.
+	var path string
+	rotateDir := ""
+	if len(positional) == 1 {
+		path = positional[0]
+		if strings.HasSuffix(path, ".gz") {
+			opts.Gzip = true
+		}
+	} else {
+		if rotateDir, err = cfg.Backup.BackupDir(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		path = filepath.Join(rotateDir, backup.FileName(time.Now(), opts))
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	if err := backup.Create(context.Background(), store, path, opts); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	var removed []string
+	if rotateDir != "" {
+		if removed, err = backup.Rotate(rotateDir, cfg.Backup.Keep); err != nil {
+			logger.Warn("backup rotation failed", "err", err)
+		}
+	}
+
+	if cf.format == "json" {
+		return printResult(printJSON(os.Stdout, map[string]any{"path": path, "rotated": removed}))
+	}
+	fmt.Println(path)
+	return exitOK
+}
+
+func runRestore(logger *slog.Logger, args []string) int {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	cf := newFormatFlags("restore", "restore [flags] <backup>", "table", "json")
+	passphraseFile := cf.fs.String("passphrase-file", cfg.Backup.PassphraseFile, "read the passphrase from this file instead of $"+passphraseEnv)
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) != 1 {
+		return cf.usageFailed("restore takes exactly one backup file")
+	}
+
+	// Plain and gzip backups need no passphrase, so a missing one is only
+	// reported if the backup turns out to be encrypted.
+	secret, _ := passphrase(*passphraseFile)
+
+	dbPath, _ := resolveDBPath(cfg, cf.dbPath)
+	result, err := backup.Restore(context.Background(), positional[0], dbPath, secret)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "restore: %v\n", err)
+		return exitFailure
+	}
+
+	if cf.format == "json" {
+		return printResult(printJSON(os.Stdout, result))
+	}
+	fmt.Printf("restored %s (schema version %d)\n", positional[0], result.SchemaVersion)
+	if result.Previous != "" {
+		fmt.Printf("previous database saved as %s\n", result.Previous)
+	}
+	return exitOK
+}
+
+func passphrase(file string) ([]byte, error) {
+	if file != "" {
+		return backup.ReadPassphrase(file)
+	}
+	if env := os.Getenv(passphraseEnv); env != "" {
+		return []byte(env), nil
+	}
+	return nil, fmt.Errorf("encryption needs -passphrase-file or $%s", passphraseEnv)
+}
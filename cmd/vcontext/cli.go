@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"vcontext/internal/config"
+	"vcontext/internal/db"
+)
+
+const (
+	exitOK       = 0
+	exitFailure  = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+var errUsage = errors.New("usage error")
+
+type commandFlags struct {
+	fs      *flag.FlagSet
+	dbPath  string
+	format  string
+	formats []string
+	json    bool
+	// config is set by openStore.
+	config config.Config
+}
+
+func newCommandFlags(name string, usage string) *commandFlags {
+	return newFormatFlags(name, usage, "table", "json", "markdown")
+}
+
+// newFormatFlags is newCommandFlags for commands with their own set of output
+// formats; the first one is the default.
+func newFormatFlags(name string, usage string, formats ...string) *commandFlags {
+	cf := &commandFlags{fs: flag.NewFlagSet(name, flag.ContinueOnError), formats: formats}
+	cf.fs.StringVar(&cf.dbPath, "db", "", "path to sqlite database")
+	cf.fs.StringVar(&cf.format, "format", formats[0], fmt.Sprintf("output format (%s)", strings.Join(formats, "|")))
+	if slices.Contains(formats, "json") {
+		cf.fs.BoolVar(&cf.json, "json", false, "shorthand for -format json")
+	}
+	cf.fs.Usage = func() {
+		fmt.Fprintf(cf.fs.Output(), "usage: vcontext %s\n", usage)
+		cf.fs.PrintDefaults()
+	}
+	return cf
+}
+
+// parse accepts flags before and after positional arguments.
+func (cf *commandFlags) parse(args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := cf.fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = cf.fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if cf.json {
+		cf.format = "json"
+	}
+	if !slices.Contains(cf.formats, cf.format) {
+		fmt.Fprintf(cf.fs.Output(), "unknown format %q (want %s)\n", cf.format, strings.Join(cf.formats, ", "))
+		return nil, errUsage
+	}
+
+	return positional, nil
+}
+
+func (cf *commandFlags) usageFailed(msg string) int {
+	fmt.Fprintln(cf.fs.Output(), msg)
+	cf.fs.Usage()
+	return exitUsage
+}
+
+func usageExit(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	return exitUsage
+}
+
+func (cf *commandFlags) openStore(logger *slog.Logger) (*db.DB, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+	cf.config = cfg
+
+	path, _ := resolveDBPath(cfg, cf.dbPath)
+	return openDB(path, logger)
+}
+
+func readContent(args []string, stdin io.Reader) (string, error) {
+	if len(args) == 1 && args[0] == "-" || len(args) == 0 && !isTerminal(os.Stdin) {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", fmt.Errorf("read stdin: %w", err)
+		}
+		return string(data), nil
+	}
+	return strings.Join(args, " "), nil
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// parseDate accepts RFC 3339 timestamps or plain dates. A plain date used as
+// an upper bound covers the whole day.
+func parseDate(value string, endOfDay bool) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.Unix(), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date %q (want YYYY-MM-DD or RFC 3339)", value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t.Unix(), nil
+}
+
+// stringsFlag collects the values of a repeatable flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"vcontext/internal/config"
+)
+
+type configEntry struct {
+	Key    string        `json:"key"`
+	Value  any           `json:"value"`
+	Source config.Source `json:"source"`
+}
+
+func runConfig(logger *slog.Logger, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: vcontext config get|set|show|path")
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "get":
+		return runConfigGet(args[1:])
+	case "set":
+		return runConfigSet(args[1:])
+	case "show":
+		return runConfigShow(args[1:])
+	case "path":
+		path, err := config.Path()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+		fmt.Println(path)
+		return exitOK
+	default:
+		fmt.Fprintf(os.Stderr, "unknown config command %q (want get, set, show or path)\n", args[0])
+		return exitUsage
+	}
+}
+
+func runConfigGet(args []string) int {
+	cf := newFormatFlags("config get", "config get [flags] <key>", "table", "json")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) != 1 {
+		return cf.usageFailed("config get takes exactly one key")
+	}
+
+	key, ok := config.Lookup(positional[0])
+	if !ok {
+		fmt.Fprintf(os.Stderr, "%s: unknown key\n", positional[0])
+		return exitUsage
+	}
+	entries, code := effectiveEntries(cf.dbPath, []config.Key{key})
+	if code != exitOK {
+		return code
+	}
+
+	if cf.format == "json" {
+		return printResult(printJSON(os.Stdout, entries[0]))
+	}
+	fmt.Println(formatConfigValue(entries[0].Value))
+	return exitOK
+}
+
+func runConfigSet(args []string) int {
+	cf := newFormatFlags("config set", "config set [flags] <key> <value>", "table")
+	project := cf.fs.Bool("project", false, "write the project config ("+config.ProjectFile+") instead of the user config")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) != 2 {
+		return cf.usageFailed("config set takes a key and a value")
+	}
+
+	path, err := config.Path()
+	layer := config.LayerUser
+	if *project {
+		layer = config.LayerProject
+		// Without a project file yet, create one in the working directory.
+		if path = config.ProjectPath(); path == "" {
+			path, err = filepath.Abs(config.ProjectFile)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	if err := config.Set(path, layer, positional[0], positional[1]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		var keyErr *config.KeyError
+		if errors.As(err, &keyErr) {
+			return exitUsage
+		}
+		return exitFailure
+	}
+	fmt.Fprintf(os.Stderr, "set %s in %s\n", positional[0], path)
+	return exitOK
+}
+
+func runConfigShow(args []string) int {
+	cf := newFormatFlags("config show", "config show [flags]", "table", "json", "markdown")
+	effective := cf.fs.Bool("effective", false, "include defaults and show where every value comes from")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) > 0 {
+		return cf.usageFailed("config show takes no arguments")
+	}
+
+	entries, code := effectiveEntries(cf.dbPath, config.Keys())
+	if code != exitOK {
+		return code
+	}
+	if !*effective {
+		set := entries[:0]
+		for _, entry := range entries {
+			if entry.Source.Layer != config.LayerDefault {
+				set = append(set, entry)
+			}
+		}
+		entries = set
+	}
+
+	if cf.format == "json" {
+		return printResult(printJSON(os.Stdout, entries))
+	}
+	rows := make([][]string, 0, len(entries))
+	for _, entry := range entries {
+		rows = append(rows, []string{entry.Key, formatConfigValue(entry.Value), entry.Source.String()})
+	}
+	return printResult(printTable(os.Stdout, cf.format, []string{"KEY", "VALUE", "SOURCE"}, rows))
+}
+
+// effectiveEntries resolves keys through every config layer and the -db
+// flag.
+func effectiveEntries(dbFlag string, keys []config.Key) ([]configEntry, int) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return nil, exitFailure
+	}
+
+	entries := make([]configEntry, 0, len(keys))
+	for _, key := range keys {
+		entry := configEntry{Key: key.Name, Value: key.Get(&cfg), Source: cfg.Source(key.Name)}
+		if key.Name == "db_path" && dbFlag != "" {
+			entry.Value, entry.Source = dbFlag, config.Source{Layer: "flag"}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, exitOK
+}
+
+func formatConfigValue(value any) string {
+	if list, ok := value.([]string); ok {
+		return strings.Join(list, ",")
+	}
+	return fmt.Sprint(value)
+}
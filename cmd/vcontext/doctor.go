@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"vcontext/internal/config"
+	"vcontext/internal/db"
+	"vcontext/internal/update"
+)
+
+const (
+	statusOK   = "ok"
+	statusWarn = "warn"
+	statusFail = "fail"
+)
+
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+}
+
+type doctorReport struct {
+	ProjectConfig string         `json:"project_config,omitempty"`
+	DBPath        string         `json:"db_path"`
+	DBPathSource  string         `json:"db_path_source"`
+	Health        *db.Health     `json:"health,omitempty"`
+	MCP           []registration `json:"mcp_registrations"`
+	Checks        []doctorCheck  `json:"checks"`
+}
+
+func (r *doctorReport) add(name string, status string, format string, args ...any) {
+	r.Checks = append(r.Checks, doctorCheck{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+func runDoctor(logger *slog.Logger, args []string) int {
+	cf := newFormatFlags("doctor", "doctor [flags]", "table", "json")
+	fix := cf.fs.Bool("fix", false, "rebuild the full-text index")
+	offline := cf.fs.Bool("offline", false, "report the cached update check instead of asking for the newest release")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) > 0 {
+		return cf.usageFailed("doctor takes no arguments")
+	}
+
+	ctx := context.Background()
+	report := &doctorReport{MCP: []registration{}, ProjectConfig: config.ProjectPath()}
+	cfg, err := loadConfig()
+	if err != nil {
+		report.add("config", statusFail, "%v", err)
+		cfg = config.Default()
+	} else {
+		report.add("config", statusOK, "%s", userConfigFile())
+	}
+	if report.ProjectConfig != "" {
+		report.add("project config", statusOK, "%s", report.ProjectConfig)
+	} else {
+		report.add("project config", statusOK, "no %s up to the git root", config.ProjectFile)
+	}
+
+	report.DBPath, report.DBPathSource = resolveDBPath(cfg, cf.dbPath)
+	report.add("db path", statusOK, "%s (from %s)", report.DBPath, report.DBPathSource)
+
+	checkDatabase(ctx, logger, report, *fix)
+	checkRegistrations(report)
+	switch {
+	case *offline:
+		checkCachedVersion(report)
+	case !cfg.Update.Check:
+		report.add("version", statusOK, "%s; update checks are off (update.check)", version)
+	default:
+		checkVersion(ctx, report, cfg)
+	}
+
+	code := exitOK
+	for _, check := range report.Checks {
+		if check.Status == statusFail {
+			code = exitFailure
+		}
+	}
+
+	if cf.format == "json" {
+		if err := printJSON(os.Stdout, report); err != nil {
+			return exitFailure
+		}
+		return code
+	}
+
+	rows := make([][]string, 0, len(report.Checks))
+	for _, check := range report.Checks {
+		rows = append(rows, []string{check.Name, check.Status, check.Detail})
+	}
+	if err := printTable(os.Stdout, cf.format, []string{"CHECK", "STATUS", "DETAIL"}, rows); err != nil {
+		return exitFailure
+	}
+	return code
+}
+
+func userConfigFile() string {
+	path, err := config.Path()
+	if err != nil {
+		return "no config directory, using defaults"
+	}
+	if _, err := os.Stat(path); err != nil {
+		return "no " + path + ", using defaults"
+	}
+	return path
+}
+
+func checkDatabase(ctx context.Context, logger *slog.Logger, report *doctorReport, fix bool) {
+	info, err := os.Stat(report.DBPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			report.add("db file", statusWarn, "does not exist yet; it is created on first use")
+		} else {
+			report.add("db file", statusFail, "%v", err)
+		}
+		return
+	}
+	walSize := int64(0)
+	if wal, err := os.Stat(report.DBPath + "-wal"); err == nil {
+		walSize = wal.Size()
+	}
+	report.add("db file", statusOK, "%s, wal %s", formatBytes(info.Size()), formatBytes(walSize))
+
+	// Open migrates the file, so the version it had is read first.
+	onDisk, err := db.FileSchemaVersion(ctx, report.DBPath)
+	if err != nil {
+		report.add("schema version", statusFail, "%v", err)
+		return
+	}
+	if onDisk > db.SchemaVersion() {
+		report.add("schema version", statusFail, "%d is newer than this binary supports (%d); run vcontext update", onDisk, db.SchemaVersion())
+		return
+	}
+
+	store, err := db.Open(report.DBPath, logger)
+	if err != nil {
+		report.add("db open", statusFail, "%v", err)
+		return
+	}
+	defer store.Close()
+
+	if fix {
+		indexed, err := store.Reindex(ctx, nil)
+		if err != nil {
+			report.add("fts rebuild", statusFail, "%v", err)
+		} else {
+			report.add("fts rebuild", statusOK, "reindexed %d items", indexed)
+		}
+	}
+
+	health, err := store.Check(ctx)
+	if err != nil {
+		report.add("db check", statusFail, "%v", err)
+		return
+	}
+	report.Health = health
+
+	if onDisk < health.SchemaVersion {
+		report.add("schema version", statusOK, "%d, migrated to %d", onDisk, health.SchemaVersion)
+	} else {
+		report.add("schema version", statusOK, "%d (binary supports %d)", health.SchemaVersion, db.SchemaVersion())
+	}
+	if len(health.Integrity) == 1 && health.Integrity[0] == "ok" {
+		report.add("integrity", statusOK, "ok")
+	} else {
+		report.add("integrity", statusFail, "%s", strings.Join(health.Integrity, "; "))
+	}
+
+	switch {
+	case health.FTSError != "":
+		report.add("fts index", statusFail, "%s; run vcontext doctor --fix", health.FTSError)
+	case !health.FTSConsistent():
+		report.add("fts index", statusFail, "%d items, %d indexed, %d missing, %d orphaned; run vcontext doctor --fix",
+			health.Items, health.Indexed, health.Missing, health.Orphaned)
+	default:
+		report.add("fts index", statusOK, "%d items, %d indexed", health.Items, health.Indexed)
+	}
+}
+
+type registration struct {
+	Client  string   `json:"client"`
+	Scope   string   `json:"scope,omitempty"`
+	Name    string   `json:"name"`
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+	Config  string   `json:"config"`
+}
+
+type mcpServerEntry struct {
+	Command string   `json:"command" toml:"command"`
+	Args    []string `json:"args" toml:"args"`
+}
+
+func checkRegistrations(report *doctorReport) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		report.add("mcp clients", statusWarn, "cannot locate home directory: %v", err)
+		return
+	}
+
+	codexHome := os.Getenv("CODEX_HOME")
+	if codexHome == "" {
+		codexHome = filepath.Join(home, ".codex")
+	}
+	sources := []struct {
+		client string
+		path   string
+		read   func(path string) ([]registration, error)
+	}{
+		{"codex", filepath.Join(codexHome, "config.toml"), codexRegistrations},
+		{"claude", filepath.Join(home, ".claude.json"), claudeRegistrations},
+	}
+
+	for _, source := range sources {
+		found, err := source.read(source.path)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				report.add("mcp "+source.client, statusWarn, "cannot read %s: %v", source.path, err)
+			}
+			continue
+		}
+		for _, reg := range found {
+			if !isVcontextServer(reg) {
+				continue
+			}
+			report.MCP = append(report.MCP, reg)
+
+			name := "mcp " + reg.Client + "/" + reg.Name
+			if reg.Scope != "" {
+				name += " (" + reg.Scope + ")"
+			}
+			command := strings.TrimSpace(reg.Command + " " + strings.Join(reg.Args, " "))
+			if _, err := exec.LookPath(reg.Command); err != nil {
+				report.add(name, statusWarn, "%s: command not found", command)
+			} else {
+				report.add(name, statusOK, "%s", command)
+			}
+		}
+	}
+
+	if len(report.MCP) == 0 {
+		report.add("mcp clients", statusWarn, "no vcontext server registered; run vcontext mcp add")
+	}
+}
+
+func codexRegistrations(path string) ([]registration, error) {
+	var file struct {
+		Servers map[string]mcpServerEntry `toml:"mcp_servers"`
+	}
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return nil, err
+	}
+	return collectRegistrations("codex", "", path, file.Servers), nil
+}
+
+func claudeRegistrations(path string) ([]registration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	type scope struct {
+		Servers map[string]mcpServerEntry `json:"mcpServers"`
+	}
+	var file struct {
+		scope
+		Projects map[string]scope `json:"projects"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	found := collectRegistrations("claude", "", path, file.Servers)
+	projects := make([]string, 0, len(file.Projects))
+	for project := range file.Projects {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	for _, project := range projects {
+		found = append(found, collectRegistrations("claude", project, path, file.Projects[project].Servers)...)
+	}
+	return found, nil
+}
+
+func collectRegistrations(client string, scope string, path string, servers map[string]mcpServerEntry) []registration {
+	names := make([]string, 0, len(servers))
+	for name := range servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	found := make([]registration, 0, len(names))
+	for _, name := range names {
+		entry := servers[name]
+		found = append(found, registration{
+			Client:  client,
+			Scope:   scope,
+			Name:    name,
+			Command: entry.Command,
+			Args:    entry.Args,
+			Config:  path,
+		})
+	}
+	return found
+}
+
+func isVcontextServer(reg registration) bool {
+	base := strings.ToLower(filepath.Base(reg.Command))
+	return strings.Contains(strings.ToLower(reg.Name), "vcontext") || strings.HasPrefix(base, "vcontext")
+}
+
+// checkCachedVersion reports the last background update check.
+func checkCachedVersion(report *doctorReport) {
+	notice, err := loadUpdateNotice()
+	switch {
+	case err != nil:
+		report.add("version", statusWarn, "%s; %v", version, err)
+	case notice.CheckedAt.IsZero():
+		report.add("version", statusOK, "%s; not checked for updates yet", version)
+	case notice.Available(version) != "":
+		report.add("version", statusWarn, "%s installed, %s available as of %s; run vcontext update",
+			version, notice.Latest, notice.CheckedAt.Local().Format(time.DateOnly))
+	default:
+		report.add("version", statusOK, "%s; no newer release as of %s", version, notice.CheckedAt.Local().Format(time.DateOnly))
+	}
+}
+
+func checkVersion(ctx context.Context, report *doctorReport, cfg config.Config) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	source, err := releaseSource(cfg, "", "", "")
+	if err != nil {
+		report.add("version", statusWarn, "%s; could not check for updates: %v", version, err)
+		return
+	}
+	latest, err := update.Check(ctx, update.Options{Source: source, CurrentVersion: version, Channel: cfg.Update.Channel})
+	switch {
+	case err != nil:
+		report.add("version", statusWarn, "%s; could not check for updates: %v", version, err)
+	case !latest.Newer:
+		report.add("version", statusOK, "%s is the latest %s release", version, cfg.Update.Channel)
+	default:
+		report.add("version", statusWarn, "%s installed, %s available; run vcontext update", version, latest.Tag)
+	}
+}
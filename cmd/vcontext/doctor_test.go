@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"vcontext/internal/db"
+)
+
+func TestDoctorReportsSchemaVersionBeforeMigrating(t *testing.T) {
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, tt := range []struct {
+		name    string
+		version int
+		status  string
+		detail  string
+		after   int
+	}{
+		{"current", db.SchemaVersion(), statusOK, fmt.Sprintf("%d (binary supports %d)", db.SchemaVersion(), db.SchemaVersion()), db.SchemaVersion()},
+		// The migrations after the second can run again on a current file.
+		{"older", 2, statusOK, fmt.Sprintf("2, migrated to %d", db.SchemaVersion()), db.SchemaVersion()},
+		{"newer", db.SchemaVersion() + 1, statusFail, fmt.Sprintf("%d is newer than this binary supports (%d); run vcontext update", db.SchemaVersion()+1, db.SchemaVersion()), db.SchemaVersion() + 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "vcontext.db")
+			store, err := db.Open(path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Close(); err != nil {
+				t.Fatal(err)
+			}
+			setUserVersion(t, path, tt.version)
+
+			report := &doctorReport{DBPath: path}
+			checkDatabase(ctx, logger, report, false)
+
+			var found *doctorCheck
+			for i := range report.Checks {
+				if report.Checks[i].Name == "schema version" {
+					found = &report.Checks[i]
+				}
+			}
+			if found == nil || found.Status != tt.status || found.Detail != tt.detail {
+				t.Fatalf("schema version check = %+v, want %s %q", found, tt.status, tt.detail)
+			}
+			if version, err := db.FileSchemaVersion(ctx, path); err != nil || version != tt.after {
+				t.Fatalf("schema version after doctor = %d, %v, want %d", version, err, tt.after)
+			}
+		})
+	}
+}
+
+func setUserVersion(t *testing.T, path string, version int) {
+	t.Helper()
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		t.Fatal(err)
+	}
+}
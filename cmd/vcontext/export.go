@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"vcontext/internal/db"
+	"vcontext/internal/export"
+)
+
+func runExport(logger *slog.Logger, args []string) int {
+	cf := newFormatFlags("export", "export [flags]", "jsonl", "markdown", "csv")
+	output := cf.fs.String("o", "", "output file (jsonl, csv; default stdout) or directory (markdown, required)")
+	namespace := cf.fs.String("namespace", "", "only export this namespace")
+	thread := cf.fs.String("thread", "", "only export this thread id")
+	tag := cf.fs.String("tag", "", "only export items with this tag")
+	since := cf.fs.String("since", "", "only export items created on or after this date")
+	until := cf.fs.String("until", "", "only export items created on or before this date")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) > 0 {
+		return cf.usageFailed("export takes no arguments")
+	}
+
+	filter := db.ListFilter{
+		Namespace: optionalString(*namespace),
+		ThreadID:  optionalString(*thread),
+		Tag:       optionalString(*tag),
+	}
+	if filter.Since, err = parseDate(*since, false); err != nil {
+		return cf.usageFailed("-since: " + err.Error())
+	}
+	if filter.Until, err = parseDate(*until, true); err != nil {
+		return cf.usageFailed("-until: " + err.Error())
+	}
+
+	format := export.Format(cf.format)
+	if format == export.FormatMarkdown && *output == "" {
+		return cf.usageFailed("-o directory is required for markdown export")
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	var writer export.Writer
+	switch format {
+	case export.FormatMarkdown:
+		writer, err = export.NewMarkdownWriter(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitFailure
+		}
+	default:
+		out := os.Stdout
+		if *output != "" && *output != "-" {
+			file, err := os.Create(*output)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "create output: %v\n", err)
+				return exitFailure
+			}
+			defer file.Close()
+			out = file
+		}
+		if format == export.FormatCSV {
+			writer = export.NewCSVWriter(out)
+		} else {
+			writer = export.NewJSONLWriter(out)
+		}
+	}
+
+	count := 0
+	err = store.EachContext(context.Background(), filter, format == export.FormatMarkdown, func(item db.ContextItem) error {
+		count++
+		return writer.Write(item)
+	})
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "export: %v\n", err)
+		return exitFailure
+	}
+
+	logger.Info("export finished", "items", count, "format", cf.format)
+	return exitOK
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+
+	"vcontext/internal/importer"
+)
+
+func runImport(logger *slog.Logger, args []string) int {
+	cf := newFormatFlags("import", "import [flags] <file|dir>...", "table", "json")
+	typeName := cf.fs.String("type", "auto", "input type (auto|jsonl|markdown|text)")
+	namespace := cf.fs.String("namespace", "", "namespace for items that do not set one")
+	dryRun := cf.fs.Bool("dry-run", false, "report what would be imported without writing")
+	batch := cf.fs.Int("batch", 500, "items per transaction")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) == 0 {
+		return cf.usageFailed("import needs at least one file or directory")
+	}
+	typ, err := importer.ParseType(*typeName)
+	if err != nil {
+		return cf.usageFailed(err.Error())
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	summary, err := importer.Run(ctx, store, positional, importer.Options{
+		Type:      typ,
+		Namespace: optionalString(*namespace),
+		DryRun:    *dryRun,
+		BatchSize: *batch,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "import: %v\n", err)
+	}
+
+	if cf.format == "json" {
+		if err := printJSON(os.Stdout, summary); err != nil {
+			return exitFailure
+		}
+	} else {
+		for _, failure := range summary.Failures {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", failure.Origin, failure.Error)
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if summary.DryRun {
+			fmt.Fprintln(tw, "dry run, nothing was written")
+		}
+		fmt.Fprintf(tw, "created:\t%d\n", summary.Created)
+		fmt.Fprintf(tw, "skipped:\t%d\n", summary.Skipped)
+		fmt.Fprintf(tw, "failed:\t%d\n", summary.Failed)
+		_ = tw.Flush()
+	}
+
+	if err != nil || summary.Failed > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -5,18 +5,25 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"vcontext/internal/backup"
 	"vcontext/internal/common"
+	"vcontext/internal/config"
 	"vcontext/internal/db"
 	"vcontext/internal/mcp"
+	"vcontext/internal/prompts"
 	"vcontext/internal/tools"
 	"vcontext/internal/update"
+	"vcontext/internal/watch"
 )
 
 var (
@@ -25,68 +32,222 @@ var (
 	date    = "unknown"
 )
 
-func main() {
-	logger := common.NewLogger()
+// loadConfig reads the layered configuration once per process.
+var loadConfig = sync.OnceValues(config.Load)
+
+type serveOptions struct {
+	dbPath        string
+	framing       string
+	log           common.LogOptions
+	watchDirs     stringsFlag
+	watchExclude  stringsFlag
+	watchInterval time.Duration
+}
 
-	if handled := handleSubcommand(logger); handled {
+func main() {
+	if handled := handleSubcommand(common.NewLogger(common.LogOptionsFromEnv())); handled {
 		return
 	}
 
-	dbPath := resolveDBPath()
-	store, err := db.Open(dbPath, logger)
+	opts := parseServeOptions(os.Args[1:])
+	stderrLogger := common.NewLogger(opts.log)
+	server := mcp.NewServer(stderrLogger)
+	server.SetInfo(mcp.ServerInfo{Name: "vcontext", Version: version})
+	logger := server.Logger()
+
+	framing, err := mcp.ParseFraming(opts.framing)
+	if err != nil {
+		common.Fatal(logger, "invalid -framing", "err", err)
+	}
+	server.SetFraming(framing)
+
+	cfg, err := loadConfig()
+	if err != nil {
+		common.Fatal(logger, "failed to load config", "err", err)
+	}
+	server.SetMaxMessageBytes(cfg.Server.MaxMessageBytes)
+
+	dbPath, _ := resolveDBPath(cfg, opts.dbPath)
+	store, err := openDB(dbPath, logger)
 	if err != nil {
-		logger.Fatalf("failed to open db: %v", err)
+		common.Fatal(logger, "failed to open db", "err", err)
 	}
 	defer func() {
 		if err := store.Close(); err != nil {
-			logger.Printf("failed to close db: %v", err)
+			logger.Error("failed to close db", "err", err)
 		}
 	}()
 
-	server := mcp.NewServer(logger)
-	server.Register("tools/save_context/invoke", tools.SaveContextHandler(store))
-	server.Register("tools/search_context/invoke", tools.SearchContextHandler(store))
-	server.Register("tools/get_context/invoke", tools.GetContextHandler(store))
+	settings := toolSettings(cfg)
+	server.RegisterTool(tools.SaveContextTool(settings), tools.SaveContextHandler(store, settings))
+	server.RegisterTool(tools.SearchContextTool(settings), tools.SearchContextHandler(store, settings))
+	server.RegisterTool(tools.GetContextTool(), tools.GetContextHandler(store))
+	server.RegisterTool(tools.BulkSaveContextTool(settings), tools.BulkSaveContextHandler(store, settings))
+	server.RegisterTool(tools.ReindexTool(), tools.ReindexHandler(store))
+	server.RegisterTool(tools.MemoryStatsTool(), tools.MemoryStatsHandler(store, settings))
+	registerPrompts(server, store, logger)
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
 	defer stop()
 
+	var background sync.WaitGroup
+	if len(opts.watchDirs) > 0 {
+		watchers, err := newWatchers(store, opts.watchDirs, watch.Options{
+			Interval: opts.watchInterval,
+			Exclude:  opts.watchExclude,
+			Logger:   logger,
+		})
+		if err != nil {
+			common.Fatal(logger, "invalid -watch", "err", err)
+		}
+		startWatchers(ctx, &background, watchers)
+	}
+	if cfg.Memory.Retention.Duration > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			runRetention(ctx, store, cfg.Memory, logger)
+		}()
+	}
+	if cfg.Update.Check && update.IsRelease(version) {
+		var notice atomic.Pointer[update.Notice]
+		if cached, err := loadUpdateNotice(); err == nil {
+			notice.Store(&cached)
+		}
+		server.SetInstructions(func() string {
+			if latest := notice.Load(); latest != nil {
+				if available := latest.Available(version); available != "" {
+					return updateNoticeText(available) + "."
+				}
+			}
+			return ""
+		})
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			runUpdateCheck(ctx, cfg, &notice, stderrLogger)
+		}()
+	}
+	if cfg.Backup.Interval.Duration > 0 {
+		schedule, err := backupSchedule(cfg.Backup, logger)
+		if err != nil {
+			common.Fatal(logger, "invalid backup config", "err", err)
+		}
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			schedule.Run(ctx, store)
+		}()
+	}
+
 	if err := server.Serve(ctx, os.Stdin, os.Stdout); err != nil {
 		if err != context.Canceled {
-			logger.Printf("server stopped: %v", err)
+			logger.Error("server stopped", "err", err)
+		}
+	}
+
+	stop()
+	background.Wait()
+}
+
+func registerPrompts(server *mcp.Server, store *db.DB, logger *slog.Logger) {
+	server.RegisterPrompt(prompts.RecallPrompt(store))
+	server.RegisterPrompt(prompts.RememberSessionPrompt(store))
+	server.RegisterPrompt(prompts.ReviewThreadPrompt(store))
+
+	dir, err := common.ConfigDir()
+	if err != nil {
+		return
+	}
+	templates, err := prompts.LoadTemplates(filepath.Join(dir, "prompts"), logger)
+	if err != nil {
+		logger.Warn("failed to load prompt templates", "err", err)
+		return
+	}
+	for _, tmpl := range templates {
+		server.RegisterPrompt(tmpl.Prompt, tmpl.Handler(store))
+	}
+}
+
+func backupSchedule(cfg config.Backup, logger *slog.Logger) (backup.Schedule, error) {
+	dir, err := cfg.BackupDir()
+	if err != nil {
+		return backup.Schedule{}, err
+	}
+	schedule := backup.Schedule{
+		Dir:      dir,
+		Interval: cfg.Interval.Duration,
+		Keep:     cfg.Keep,
+		Options:  backup.Options{Gzip: cfg.Gzip},
+		Logger:   logger,
+	}
+	if cfg.PassphraseFile != "" {
+		if schedule.Options.Passphrase, err = backup.ReadPassphrase(cfg.PassphraseFile); err != nil {
+			return backup.Schedule{}, err
 		}
 	}
+	return schedule, nil
 }
 
-func resolveDBPath() string {
-	var dbPath string
+func parseServeOptions(args []string) serveOptions {
+	opts := serveOptions{log: common.LogOptionsFromEnv()}
 	fs := flag.NewFlagSet("vcontext", flag.ExitOnError)
-	fs.StringVar(&dbPath, "db", "", "path to sqlite database")
-	_ = fs.Parse(os.Args[1:])
+	fs.StringVar(&opts.dbPath, "db", "", "path to sqlite database")
+	fs.StringVar(&opts.framing, "framing", "line", "stdio framing (line|content-length)")
+	fs.StringVar(&opts.log.Level, "log-level", opts.log.Level, "log level (debug|info|warn|error)")
+	fs.StringVar(&opts.log.Format, "log-format", opts.log.Format, "log format (text|json)")
+	fs.StringVar(&opts.log.File, "log-file", opts.log.File, "also write logs to a rotating file (\"default\" for the config dir)")
+	fs.Var(&opts.watchDirs, "watch", "keep the notes in this directory indexed while serving (repeatable)")
+	fs.Var(&opts.watchExclude, "watch-exclude", "gitignore-style pattern skipped by -watch (repeatable)")
+	fs.DurationVar(&opts.watchInterval, "watch-interval", watch.DefaultInterval, "polling interval for -watch")
+	_ = fs.Parse(args)
+	return opts
+}
 
-	if dbPath != "" {
-		return dbPath
+// resolveDBPath returns the database path and where it came from: "flag",
+// the config layer that set db_path, or "default".
+func resolveDBPath(cfg config.Config, flagValue string) (string, string) {
+	if flagValue != "" {
+		return flagValue, "flag"
 	}
 
-	if env := os.Getenv("VCONTEXT_DB_PATH"); env != "" {
-		return env
+	if cfg.DBPath != "" {
+		return cfg.DBPath, cfg.Source("db_path").String()
+	}
+
+	return defaultDBPath(), config.LayerDefault
+}
+
+// openDB opens the database, creating its directory first so that a
+// db_path such as .vcontext/memory.db works in a fresh checkout.
+func openDB(path string, logger *slog.Logger) (*db.DB, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
 	}
+	return db.Open(path, logger)
+}
 
-	return defaultDBPath()
+func toolSettings(cfg config.Config) tools.Settings {
+	return tools.Settings{
+		DefaultTopK:       cfg.Tools.DefaultTopK,
+		MaxTopK:           cfg.Tools.MaxTopK,
+		DefaultImportance: cfg.Tools.DefaultImportance,
+		Namespace:         optionalString(cfg.Memory.Namespace),
+		Tags:              cfg.Memory.Tags,
+	}
 }
 
 func defaultDBPath() string {
-	configDir, err := os.UserConfigDir()
-	if err != nil || configDir == "" {
+	dir, err := common.ConfigDir()
+	if err != nil {
 		return "vcontext.db"
 	}
 
-	dir := filepath.Join(configDir, "vcontext")
 	_ = os.MkdirAll(dir, 0o755)
 	return filepath.Join(dir, "vcontext.db")
 }
 
-func handleSubcommand(logger *log.Logger) bool {
+func handleSubcommand(logger *slog.Logger) bool {
 	args := os.Args[1:]
 	if len(args) == 0 {
 		return false
@@ -95,6 +256,10 @@ func handleSubcommand(logger *log.Logger) bool {
 	switch strings.ToLower(args[0]) {
 	case "version", "--version", "-version":
 		fmt.Printf("vcontext %s (%s) %s\n", version, commit, date)
+		printUpdateNotice()
+		return true
+	case refreshNoticeCommand:
+		runNoticeRefresh()
 		return true
 	case "update":
 		runUpdate(logger, args[1:])
@@ -102,39 +267,130 @@ func handleSubcommand(logger *log.Logger) bool {
 	case "mcp":
 		runMCP(logger, args[1:])
 		return true
-	default:
+	}
+
+	commands := map[string]func(*slog.Logger, []string) int{
+		"save":    runSave,
+		"search":  runSearch,
+		"get":     runGet,
+		"list":    runList,
+		"delete":  runDelete,
+		"threads": runThreads,
+		"stats":   runStats,
+		"export":  runExport,
+		"import":  runImport,
+		"watch":   runWatch,
+		"backup":  runBackup,
+		"restore": runRestore,
+		"doctor":  runDoctor,
+		"config":  runConfig,
+	}
+	run, ok := commands[strings.ToLower(args[0])]
+	if !ok {
 		return false
 	}
+	if code := run(logger, args[1:]); code != exitOK {
+		os.Exit(code)
+	}
+	return true
 }
 
-func runUpdate(logger *log.Logger, args []string) {
+func runUpdate(logger *slog.Logger, args []string) {
 	fs := flag.NewFlagSet("update", flag.ExitOnError)
 	repo := fs.String("repo", "", "GitHub repo (org/name)")
+	source := fs.String("source", "", "release source (github|manifest|dir); defaults to update.source")
+	sourceURL := fs.String("url", "", "GitHub API base URL, manifest URL or release directory; defaults to update.url")
+	channel := fs.String("channel", "", "release channel (stable|prerelease); defaults to update.channel")
+	pinned := fs.String("version", "", "install this release, even an older one")
+	check := fs.Bool("check", false, "only report whether an update is available")
+	rollback := fs.Bool("rollback", false, "restore the binary replaced by the last update")
+	insecure := fs.Bool("insecure", false, "install releases that are not signed or cannot be verified")
 	_ = fs.Parse(args)
 
-	if *repo == "" {
-		if env := strings.TrimSpace(os.Getenv("VCONTEXT_UPDATE_REPO")); env != "" {
-			*repo = env
+	if *rollback {
+		if err := update.Rollback(); err != nil {
+			common.Fatal(logger, "rollback failed", "err", err)
+		}
+		logger.Info("restored the previous binary, please restart the server")
+		return
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		common.Fatal(logger, "failed to load config", "err", err)
+	}
+	releases, err := releaseSource(cfg, *repo, *source, *sourceURL)
+	if err != nil {
+		common.Fatal(logger, "invalid release source", "err", err)
+	}
+	opts := update.Options{
+		Source:         releases,
+		CurrentVersion: version,
+		Channel:        cfg.Update.Channel,
+		Version:        *pinned,
+		Insecure:       *insecure,
+	}
+	if *channel != "" {
+		opts.Channel = *channel
+	}
+
+	if *check {
+		release, err := update.Check(context.Background(), opts)
+		if err != nil {
+			common.Fatal(logger, "update check failed", "err", err)
+		}
+		if release.Newer {
+			fmt.Printf("%s available (installed %s)\n", release.Tag, version)
 		} else {
-			*repo = "vietrix/vcontext"
+			fmt.Printf("%s is up to date (latest %s)\n", version, release.Tag)
 		}
+		return
+	}
+	if *insecure {
+		logger.Warn("signature verification is disabled for this update")
 	}
 
-	tag, err := update.SelfUpdate(context.Background(), *repo, version)
+	tag, err := update.SelfUpdate(context.Background(), opts)
 	if err != nil {
 		if errors.Is(err, update.ErrAlreadyLatest) {
-			logger.Printf("already up to date (%s)", version)
+			logger.Info("already up to date", "version", version)
 			return
 		}
-		logger.Fatalf("update failed: %v", err)
+		if errors.Is(err, update.ErrUnverified) {
+			common.Fatal(logger, "update refused, pass -insecure to install it anyway", "err", err)
+		}
+		common.Fatal(logger, "update failed", "err", err)
 	}
 
-	logger.Printf("updated to %s, please restart the server", tag)
+	logger.Info("updated, please restart the server", "version", tag)
+}
+
+func updateRepo(cfg config.Config, flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return cfg.Update.Repo
+}
+
+// releaseSource builds the update source from the flags and the config. A
+// -source flag does not take update.url, which belongs to another kind.
+// The GitHub token only goes to a URL from a flag, the user config or the
+// environment, never to one a project file picked.
+func releaseSource(cfg config.Config, repo string, kind string, location string) (update.ReleaseSource, error) {
+	trusted := location != ""
+	if kind == "" {
+		kind = cfg.Update.Source
+		if location == "" {
+			location = cfg.Update.URL
+			trusted = cfg.Source("update.url").Layer != config.LayerProject
+		}
+	}
+	return update.NewSource(kind, location, updateRepo(cfg, repo), trusted)
 }
 
-func runMCP(logger *log.Logger, args []string) {
+func runMCP(logger *slog.Logger, args []string) {
 	if len(args) == 0 {
-		logger.Printf("usage: vcontext mcp add [codex|claude] [--db path] [--name name]")
+		fmt.Fprintln(os.Stderr, "usage: vcontext mcp add [codex|claude] [--db path] [--name name]")
 		return
 	}
 
@@ -142,11 +398,11 @@ func runMCP(logger *log.Logger, args []string) {
 	case "add":
 		runMCPAdd(logger, args[1:])
 	default:
-		logger.Printf("unknown mcp command: %s", args[0])
+		logger.Error("unknown mcp command", "command", args[0])
 	}
 }
 
-func runMCPAdd(logger *log.Logger, args []string) {
+func runMCPAdd(logger *slog.Logger, args []string) {
 	fs := flag.NewFlagSet("mcp add", flag.ExitOnError)
 	client := fs.String("client", "", "mcp client (codex|claude)")
 	name := fs.String("name", "vcontext", "server name")
@@ -165,7 +421,7 @@ func runMCPAdd(logger *log.Logger, args []string) {
 		} else if _, err := exec.LookPath("claude"); err == nil {
 			*client = "claude"
 		} else {
-			logger.Printf("could not find codex or claude in PATH; specify --client")
+			logger.Error("could not find codex or claude in PATH; specify --client")
 			return
 		}
 	}
@@ -173,12 +429,12 @@ func runMCPAdd(logger *log.Logger, args []string) {
 	if *serverPath == "" {
 		exePath, err := os.Executable()
 		if err != nil {
-			logger.Printf("resolve executable: %v", err)
+			logger.Error("resolve executable", "err", err)
 			return
 		}
 		exePath, err = filepath.Abs(exePath)
 		if err != nil {
-			logger.Printf("resolve executable: %v", err)
+			logger.Error("resolve executable", "err", err)
 			return
 		}
 		*serverPath = exePath
@@ -198,13 +454,13 @@ func runMCPAdd(logger *log.Logger, args []string) {
 		cmdArgs := append([]string{"mcp", "add", "--transport", "stdio", *name, "--", *serverPath}, serverArgs...)
 		cmd = exec.Command("claude", cmdArgs...)
 	default:
-		logger.Printf("unsupported client: %s", *client)
+		logger.Error("unsupported client", "client", *client)
 		return
 	}
 
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Run(); err != nil {
-		logger.Printf("mcp add failed: %v", err)
+		logger.Error("mcp add failed", "err", err)
 	}
 }
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"vcontext/internal/db"
+	"vcontext/internal/mcp"
+	"vcontext/internal/tools"
+)
+
+func runSave(logger *slog.Logger, args []string) int {
+	cf := newCommandFlags("save", "save [flags] [content | -]")
+	namespace := cf.fs.String("namespace", "", "namespace")
+	title := cf.fs.String("title", "", "item title")
+	thread := cf.fs.String("thread", "", "thread id")
+	source := cf.fs.String("source", "", "item source")
+	role := cf.fs.String("role", "", "item role")
+	tags := cf.fs.String("tags", "", "comma separated tags")
+	importance := cf.fs.Int("importance", 0, "importance from 1 (minor) to 5 (critical)")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+
+	content, err := readContent(positional, os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	input := tools.SaveContextParams{
+		Content:   content,
+		Namespace: optionalString(*namespace),
+		Title:     optionalString(*title),
+		ThreadID:  optionalString(*thread),
+		Source:    optionalString(*source),
+		Role:      optionalString(*role),
+	}
+	if list := splitList(*tags); len(list) > 0 {
+		input.Tags = &list
+	}
+	if *importance != 0 {
+		input.Importance = importance
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	result, rpcErr := tools.SaveContext(context.Background(), store, toolSettings(cf.config), input)
+	if rpcErr != nil {
+		return rpcExit(rpcErr)
+	}
+
+	if cf.format == "json" {
+		return printResult(printJSON(os.Stdout, result))
+	}
+	fmt.Println(result.ID)
+	return exitOK
+}
+
+func runSearch(logger *slog.Logger, args []string) int {
+	cf := newCommandFlags("search", "search [flags] <query>")
+	topK := cf.fs.Int("k", 0, "maximum number of results")
+	namespace := cf.fs.String("namespace", "", "restrict to a namespace")
+	thread := cf.fs.String("thread", "", "restrict to a thread id")
+	minImportance := cf.fs.Int("min-importance", 0, "minimum importance")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+
+	query, err := readContent(positional, os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	input := tools.SearchContextParams{
+		Query:     query,
+		Namespace: optionalString(*namespace),
+		ThreadID:  optionalString(*thread),
+	}
+	if *topK != 0 {
+		input.TopK = topK
+	}
+	if *minImportance != 0 {
+		input.MinImportance = minImportance
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	result, rpcErr := tools.SearchContext(context.Background(), store, toolSettings(cf.config), input)
+	if rpcErr != nil {
+		return rpcExit(rpcErr)
+	}
+
+	return printResult(printSearchResults(os.Stdout, cf.format, result.Items))
+}
+
+func runGet(logger *slog.Logger, args []string) int {
+	cf := newCommandFlags("get", "get [flags] <id>")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) != 1 {
+		return cf.usageFailed("get takes exactly one id")
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	item, rpcErr := tools.GetContext(context.Background(), store, tools.GetContextParams{ID: positional[0]})
+	if rpcErr != nil {
+		return rpcExit(rpcErr)
+	}
+
+	return printResult(printItem(os.Stdout, cf.format, item))
+}
+
+func runList(logger *slog.Logger, args []string) int {
+	cf := newCommandFlags("list", "list [flags]")
+	namespace := cf.fs.String("namespace", "", "restrict to a namespace")
+	thread := cf.fs.String("thread", "", "restrict to a thread id")
+	source := cf.fs.String("source", "", "restrict to a source")
+	limit := cf.fs.Int("limit", 20, "maximum number of items")
+	offset := cf.fs.Int("offset", 0, "number of items to skip")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) > 0 {
+		return cf.usageFailed("list takes no arguments")
+	}
+	if *limit < 1 || *offset < 0 {
+		return cf.usageFailed("-limit must be positive and -offset must not be negative")
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	if *namespace == "" {
+		*namespace = cf.config.Memory.Namespace
+	}
+	items, err := store.ListContext(context.Background(), db.ListFilter{
+		Namespace: optionalString(*namespace),
+		ThreadID:  optionalString(*thread),
+		Source:    optionalString(*source),
+		Limit:     *limit,
+		Offset:    *offset,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	return printResult(printItems(os.Stdout, cf.format, items))
+}
+
+func runDelete(logger *slog.Logger, args []string) int {
+	cf := newCommandFlags("delete", "delete [flags] <id>...")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) == 0 {
+		return cf.usageFailed("delete needs at least one id")
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	code := exitOK
+	deleted := make([]string, 0, len(positional))
+	for _, id := range positional {
+		if err := store.DeleteContext(context.Background(), id); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", id, err)
+			// A failure outranks a missing id, whatever the order.
+			if errors.Is(err, db.ErrNotFound) {
+				if code == exitOK {
+					code = exitNotFound
+				}
+			} else {
+				code = exitFailure
+			}
+			continue
+		}
+		deleted = append(deleted, id)
+	}
+
+	if cf.format == "json" {
+		if err := printJSON(os.Stdout, map[string][]string{"deleted": deleted}); err != nil {
+			return exitFailure
+		}
+		return code
+	}
+	for _, id := range deleted {
+		fmt.Println(id)
+	}
+	return code
+}
+
+func runThreads(logger *slog.Logger, args []string) int {
+	cf := newCommandFlags("threads", "threads [flags]")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) > 0 {
+		return cf.usageFailed("threads takes no arguments")
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	threads, err := store.ListThreads(context.Background())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+
+	return printResult(printThreads(os.Stdout, cf.format, threads))
+}
+
+func runStats(logger *slog.Logger, args []string) int {
+	cf := newCommandFlags("stats", "stats [flags]")
+	namespace := cf.fs.String("namespace", "", "restrict to a namespace")
+	top := cf.fs.Int("top", 0, "entries per breakdown")
+	days := cf.fs.Int("days", 0, "days covered by the per-day counts")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) > 0 {
+		return cf.usageFailed("stats takes no arguments")
+	}
+
+	input := tools.MemoryStatsParams{Namespace: optionalString(*namespace)}
+	if *top != 0 {
+		input.Top = top
+	}
+	if *days != 0 {
+		input.Days = days
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	stats, rpcErr := tools.MemoryStats(context.Background(), store, toolSettings(cf.config), input)
+	if rpcErr != nil {
+		return rpcExit(rpcErr)
+	}
+
+	return printResult(printStats(os.Stdout, cf.format, stats))
+}
+
+func rpcExit(rpcErr *mcp.RPCError) int {
+	fmt.Fprintln(os.Stderr, rpcErr.Message)
+	switch rpcErr.Code {
+	case mcp.ErrNotFound:
+		return exitNotFound
+	case mcp.ErrInvalidParams:
+		return exitUsage
+	default:
+		return exitFailure
+	}
+}
+
+func printResult(err error) int {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitFailure
+	}
+	return exitOK
+}
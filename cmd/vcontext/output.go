@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"vcontext/internal/db"
+)
+
+func printJSON(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+func printTable(w io.Writer, format string, header []string, rows [][]string) error {
+	if format == "markdown" {
+		fmt.Fprintf(w, "| %s |\n", strings.Join(header, " | "))
+		separators := make([]string, len(header))
+		for i := range separators {
+			separators[i] = "---"
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(separators, " | "))
+		for _, row := range rows {
+			cells := make([]string, len(row))
+			for i, cell := range row {
+				cells[i] = strings.ReplaceAll(cell, "|", `\|`)
+			}
+			fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		}
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func printItems(w io.Writer, format string, items []db.ContextItem) error {
+	if format == "json" {
+		return printJSON(w, items)
+	}
+	rows := make([][]string, 0, len(items))
+	for _, item := range items {
+		rows = append(rows, []string{
+			item.ID,
+			formatUnix(item.CreatedAt),
+			deref(item.ThreadID),
+			strconv.Itoa(item.Importance),
+			summary(item.Title, item.Content),
+		})
+	}
+	return printTable(w, format, []string{"ID", "CREATED", "THREAD", "IMPORTANCE", "TITLE"}, rows)
+}
+
+func printSearchResults(w io.Writer, format string, results []db.SearchResult) error {
+	if format == "json" {
+		return printJSON(w, results)
+	}
+	rows := make([][]string, 0, len(results))
+	for _, result := range results {
+		rows = append(rows, []string{
+			result.ID,
+			deref(result.ThreadID),
+			strconv.Itoa(result.Importance),
+			summary(result.Title, ""),
+			oneLine(result.Snippet),
+		})
+	}
+	return printTable(w, format, []string{"ID", "THREAD", "IMPORTANCE", "TITLE", "SNIPPET"}, rows)
+}
+
+func printItem(w io.Writer, format string, item *db.ContextItem) error {
+	switch format {
+	case "json":
+		return printJSON(w, item)
+	case "markdown":
+		fmt.Fprintf(w, "# %s\n\n", summary(item.Title, item.Content))
+		fmt.Fprintf(w, "- id: `%s`\n", item.ID)
+		fmt.Fprintf(w, "- created: %s\n", formatUnix(item.CreatedAt))
+		writeOptional(w, "- %s: %s\n", "namespace", item.Namespace)
+		writeOptional(w, "- %s: %s\n", "thread", item.ThreadID)
+		writeOptional(w, "- %s: %s\n", "source", item.Source)
+		writeOptional(w, "- %s: %s\n", "role", item.Role)
+		if item.Tags != nil && len(*item.Tags) > 0 {
+			fmt.Fprintf(w, "- tags: %s\n", strings.Join(*item.Tags, ", "))
+		}
+		fmt.Fprintf(w, "- importance: %d\n\n", item.Importance)
+		fmt.Fprintln(w, strings.TrimSpace(item.Content))
+		return nil
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "id:\t%s\n", item.ID)
+		fmt.Fprintf(tw, "created:\t%s\n", formatUnix(item.CreatedAt))
+		writeOptional(tw, "%s:\t%s\n", "title", item.Title)
+		writeOptional(tw, "%s:\t%s\n", "namespace", item.Namespace)
+		writeOptional(tw, "%s:\t%s\n", "thread", item.ThreadID)
+		writeOptional(tw, "%s:\t%s\n", "source", item.Source)
+		writeOptional(tw, "%s:\t%s\n", "role", item.Role)
+		if item.Tags != nil && len(*item.Tags) > 0 {
+			fmt.Fprintf(tw, "tags:\t%s\n", strings.Join(*item.Tags, ", "))
+		}
+		fmt.Fprintf(tw, "importance:\t%d\n", item.Importance)
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(item.Content))
+		return nil
+	}
+}
+
+func printThreads(w io.Writer, format string, threads []db.ThreadSummary) error {
+	if format == "json" {
+		return printJSON(w, threads)
+	}
+	rows := make([][]string, 0, len(threads))
+	for _, thread := range threads {
+		rows = append(rows, []string{
+			thread.ThreadID,
+			strconv.Itoa(thread.Items),
+			formatUnix(thread.FirstAt),
+			formatUnix(thread.LastAt),
+		})
+	}
+	return printTable(w, format, []string{"THREAD", "ITEMS", "FIRST", "LAST"}, rows)
+}
+
+func printStats(w io.Writer, format string, stats *db.Stats) error {
+	if format == "json" {
+		return printJSON(w, stats)
+	}
+
+	overview := [][]string{
+		{"items", strconv.Itoa(stats.Items)},
+		{"content", formatBytes(stats.ContentBytes)},
+	}
+	if stats.Items > 0 {
+		overview = append(overview,
+			[]string{"first", formatUnix(stats.FirstAt)},
+			[]string{"last", formatUnix(stats.LastAt)},
+		)
+	}
+	overview = append(overview,
+		[]string{"database", formatBytes(stats.DatabaseBytes)},
+		[]string{"search index", formatBytes(stats.IndexBytes)},
+	)
+	if err := printSection(w, format, "Overview", []string{"STAT", "VALUE"}, overview); err != nil {
+		return err
+	}
+
+	breakdowns := []struct {
+		title  string
+		column string
+		counts []db.ValueCount
+	}{
+		{"Namespaces", "NAMESPACE", stats.Namespaces},
+		{"Threads", "THREAD", stats.Threads},
+		{"Sources", "SOURCE", stats.Sources},
+		{"Roles", "ROLE", stats.Roles},
+		{"Tags", "TAG", stats.Tags},
+	}
+	for _, breakdown := range breakdowns {
+		rows := make([][]string, 0, len(breakdown.counts))
+		for _, count := range breakdown.counts {
+			value := "(none)"
+			if count.Value != nil {
+				value = *count.Value
+			}
+			rows = append(rows, []string{value, strconv.Itoa(count.Items), formatBytes(count.Bytes)})
+		}
+		if err := printSection(w, format, breakdown.title, []string{breakdown.column, "ITEMS", "BYTES"}, rows); err != nil {
+			return err
+		}
+	}
+
+	rows := make([][]string, 0, len(stats.Importance))
+	for _, count := range stats.Importance {
+		rows = append(rows, []string{strconv.Itoa(count.Importance), strconv.Itoa(count.Items)})
+	}
+	if err := printSection(w, format, "Importance", []string{"IMPORTANCE", "ITEMS"}, rows); err != nil {
+		return err
+	}
+
+	rows = make([][]string, 0, len(stats.PerDay))
+	for _, count := range stats.PerDay {
+		rows = append(rows, []string{count.Day, strconv.Itoa(count.Items)})
+	}
+	if err := printSection(w, format, "Per day", []string{"DAY", "ITEMS"}, rows); err != nil {
+		return err
+	}
+
+	rows = make([][]string, 0, len(stats.Largest))
+	for _, item := range stats.Largest {
+		rows = append(rows, []string{item.ID, formatBytes(item.Bytes), deref(item.Namespace), deref(item.ThreadID), summary(item.Title, "")})
+	}
+	return printSection(w, format, "Largest items", []string{"ID", "SIZE", "NAMESPACE", "THREAD", "TITLE"}, rows)
+}
+
+func printSection(w io.Writer, format string, title string, header []string, rows [][]string) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	if format == "markdown" {
+		fmt.Fprintf(w, "## %s\n\n", title)
+		defer fmt.Fprintln(w)
+	} else if title != "Overview" {
+		fmt.Fprintln(w)
+	}
+	return printTable(w, format, header, rows)
+}
+
+func writeOptional(w io.Writer, layout string, label string, value *string) {
+	if value == nil || *value == "" {
+		return
+	}
+	fmt.Fprintf(w, layout, label, *value)
+}
+
+func formatUnix(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format("2006-01-02 15:04")
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func summary(title *string, content string) string {
+	if title != nil && strings.TrimSpace(*title) != "" {
+		return oneLine(*title)
+	}
+	return oneLine(content)
+}
+
+func oneLine(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > 60 {
+		text = string(runes[:60]) + "..."
+	}
+	return text
+}
+
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"vcontext/internal/config"
+	"vcontext/internal/db"
+)
+
+const retentionInterval = time.Hour
+
+// runRetention deletes items older than the configured retention, within
+// the configured namespace if there is one, at start and then hourly.
+func runRetention(ctx context.Context, store *db.DB, memory config.Memory, logger *slog.Logger) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := deleteExpired(ctx, store, memory)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Error("failed to delete expired items", "err", err)
+		case deleted > 0:
+			logger.Info("deleted expired items", "count", deleted, "retention", memory.Retention.Duration)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deleteExpired deletes the items older than the retention. Items of watched
+// files are kept: their created_at is the mtime of the file, and the file,
+// not its age, decides when they go.
+func deleteExpired(ctx context.Context, store *db.DB, memory config.Memory) (int, error) {
+	return store.DeleteContexts(ctx, db.ListFilter{
+		Namespace: optionalString(memory.Namespace),
+		Until:     time.Now().Add(-memory.Retention.Duration).Unix(),
+		Unwatched: true,
+	})
+}
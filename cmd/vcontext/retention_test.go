@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"vcontext/internal/config"
+	"vcontext/internal/db"
+	"vcontext/internal/watch"
+)
+
+// A watched note older than the retention keeps its items: the watcher
+// would see the file as unchanged and never index it again.
+func TestRetentionKeepsWatchedFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := db.Open(filepath.Join(dir, "vcontext.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	notes := filepath.Join(dir, "notes")
+	if err := os.Mkdir(notes, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	note := filepath.Join(notes, "old.md")
+	if err := os.WriteFile(note, []byte("# Old note\n\nWritten long ago.\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().AddDate(-1, 0, 0)
+	if err := os.Chtimes(note, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.InsertContext(ctx, db.ContextItem{ID: "saved", CreatedAt: old.Unix(), Content: "an old saved item", Importance: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	watcher, err := watch.New(store, notes, watch.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := watcher.Sync(ctx); err != nil || result.Indexed != 1 {
+		t.Fatalf("first sync = %+v, %v", result, err)
+	}
+
+	memory := config.Memory{Retention: config.Duration{Duration: 30 * 24 * time.Hour}}
+	deleted, err := deleteExpired(ctx, store, memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 1 {
+		t.Fatalf("deleted %d items, want only the saved one", deleted)
+	}
+
+	if result, err := watcher.Sync(ctx); err != nil || result.Unchanged != 1 {
+		t.Fatalf("second sync = %+v, %v", result, err)
+	}
+	items, err := store.ListContext(ctx, db.ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].Source == nil {
+		t.Fatalf("items after retention = %+v, want the watched note", items)
+	}
+}
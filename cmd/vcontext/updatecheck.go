@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"vcontext/internal/common"
+	"vcontext/internal/config"
+	"vcontext/internal/update"
+)
+
+// updateCheckInterval is how often the server asks whether the daily
+// update check is due.
+const updateCheckInterval = time.Hour
+
+func updateNoticePath() (string, error) {
+	dir, err := common.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "update-check.json"), nil
+}
+
+func loadUpdateNotice() (update.Notice, error) {
+	path, err := updateNoticePath()
+	if err != nil {
+		return update.Notice{}, err
+	}
+	return update.LoadNotice(path)
+}
+
+// refreshUpdateNotice runs the update check when the cached one is a day
+// old.
+func refreshUpdateNotice(ctx context.Context, cfg config.Config) (update.Notice, error) {
+	path, err := updateNoticePath()
+	if err != nil {
+		return update.Notice{}, err
+	}
+	source, err := releaseSource(cfg, "", "", "")
+	if err != nil {
+		return update.Notice{}, err
+	}
+	return update.RefreshNotice(ctx, path, update.Options{
+		Source:         source,
+		CurrentVersion: version,
+		Channel:        cfg.Update.Channel,
+	})
+}
+
+// refreshNoticeCommand is the hidden subcommand printUpdateNotice starts to
+// refresh the cached notice after version has exited.
+const refreshNoticeCommand = "refresh-update-notice"
+
+// printUpdateNotice tells on stderr about a newer release from the cached
+// notice. It never waits for the network: a stale cache is refreshed by a
+// detached process and shows up on the next run.
+func printUpdateNotice() {
+	if !update.IsRelease(version) {
+		return
+	}
+	cfg, err := loadConfig()
+	if err != nil || !cfg.Update.Check {
+		return
+	}
+	notice, err := loadUpdateNotice()
+	if err != nil {
+		return
+	}
+	if available := notice.Available(version); available != "" {
+		fmt.Fprintln(os.Stderr, updateNoticeText(available))
+	}
+	if notice.Stale(cfg.Update.Channel) {
+		startNoticeRefresh()
+	}
+}
+
+func startNoticeRefresh() {
+	exePath, err := os.Executable()
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(exePath, refreshNoticeCommand)
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	_ = cmd.Process.Release()
+}
+
+// runNoticeRefresh is the detached update check of printUpdateNotice. It
+// prints nothing; the outcome is only cached.
+func runNoticeRefresh() {
+	cfg, err := loadConfig()
+	if err != nil || !cfg.Update.Check {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, _ = refreshUpdateNotice(ctx, cfg)
+}
+
+func updateNoticeText(latest string) string {
+	return fmt.Sprintf("vcontext %s is available (running %s); run vcontext update", latest, version)
+}
+
+// runUpdateCheck keeps notice current for the lifetime of the server. It
+// only logs, to stderr and the log file, and never writes to stdout.
+func runUpdateCheck(ctx context.Context, cfg config.Config, notice *atomic.Pointer[update.Notice], logger *slog.Logger) {
+	ticker := time.NewTicker(updateCheckInterval)
+	defer ticker.Stop()
+
+	announced := ""
+	for {
+		checkCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		latest, err := refreshUpdateNotice(checkCtx, cfg)
+		cancel()
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.Debug("update check failed", "err", err)
+		case latest.Error != "":
+			logger.Debug("update check failed", "err", latest.Error)
+		}
+		if !latest.CheckedAt.IsZero() {
+			notice.Store(&latest)
+		}
+		if available := latest.Available(version); available != "" && available != announced {
+			announced = available
+			logger.Info("update available", "version", available, "current", version)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
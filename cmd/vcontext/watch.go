@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"text/tabwriter"
+
+	"vcontext/internal/db"
+	"vcontext/internal/watch"
+)
+
+func runWatch(logger *slog.Logger, args []string) int {
+	cf := newFormatFlags("watch", "watch [flags] <dir>...", "table", "json")
+	interval := cf.fs.Duration("interval", watch.DefaultInterval, "polling interval")
+	namespace := cf.fs.String("namespace", "", "namespace for items that do not set one")
+	once := cf.fs.Bool("once", false, "sync once and exit")
+	var excludes stringsFlag
+	cf.fs.Var(&excludes, "exclude", "gitignore-style pattern to skip (repeatable)")
+	positional, err := cf.parse(args)
+	if err != nil {
+		return usageExit(err)
+	}
+	if len(positional) == 0 {
+		return cf.usageFailed("watch needs at least one directory")
+	}
+
+	store, err := cf.openStore(logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open db: %v\n", err)
+		return exitFailure
+	}
+	defer store.Close()
+
+	watchers, err := newWatchers(store, positional, watch.Options{
+		Interval:  *interval,
+		Exclude:   excludes,
+		Namespace: optionalString(*namespace),
+		Logger:    logger,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitUsage
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if !*once {
+		logger.Info("watching for changes", "dirs", len(watchers), "interval", interval.String())
+		var wg sync.WaitGroup
+		startWatchers(ctx, &wg, watchers)
+		wg.Wait()
+		return exitOK
+	}
+
+	code := exitOK
+	results := make(map[string]watch.Result, len(watchers))
+	for _, w := range watchers {
+		result, err := w.Sync(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", w.Root(), err)
+			code = exitFailure
+			continue
+		}
+		if result.Failed > 0 {
+			code = exitFailure
+		}
+		results[w.Root()] = result
+	}
+
+	if cf.format == "json" {
+		if err := printJSON(os.Stdout, results); err != nil {
+			return exitFailure
+		}
+		return code
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DIR\tINDEXED\tREMOVED\tUNCHANGED\tFAILED")
+	for _, w := range watchers {
+		if result, ok := results[w.Root()]; ok {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", w.Root(), result.Indexed, result.Removed, result.Unchanged, result.Failed)
+		}
+	}
+	_ = tw.Flush()
+	return code
+}
+
+func newWatchers(store *db.DB, dirs []string, opts watch.Options) ([]*watch.Watcher, error) {
+	watchers := make([]*watch.Watcher, 0, len(dirs))
+	for _, dir := range dirs {
+		w, err := watch.New(store, dir, opts)
+		if err != nil {
+			return nil, fmt.Errorf("watch %s: %w", dir, err)
+		}
+		watchers = append(watchers, w)
+	}
+	return watchers, nil
+}
+
+func startWatchers(ctx context.Context, wg *sync.WaitGroup, watchers []*watch.Watcher) {
+	for _, w := range watchers {
+		wg.Add(1)
+		go func(w *watch.Watcher) {
+			defer wg.Done()
+			_ = w.Run(ctx)
+		}(w)
+	}
+}
@@ -0,0 +1,368 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"vcontext/internal/db"
+)
+
+const (
+	filePrefix   = "vcontext-"
+	sqliteHeader = "SQLite format 3\x00"
+	gzipMagic    = "\x1f\x8b"
+)
+
+type Options struct {
+	Gzip bool
+	// Passphrase enables encryption when set.
+	Passphrase []byte
+}
+
+// FileName names a scheduled backup taken at t; names sort by time.
+func FileName(t time.Time, opts Options) string {
+	name := filePrefix + t.UTC().Format("20060102-150405") + ".db"
+	if opts.Gzip {
+		name += ".gz"
+	}
+	if opts.Passphrase != nil {
+		name += ".enc"
+	}
+	return name
+}
+
+// Create writes a snapshot of store to path, compressing and encrypting it
+// as requested. The file appears only once it is complete.
+func Create(ctx context.Context, store *db.DB, path string, opts Options) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create backup dir: %w", err)
+	}
+
+	snapshot := filepath.Join(dir, "."+filepath.Base(path)+".snapshot")
+	_ = os.Remove(snapshot)
+	if err := store.Backup(ctx, snapshot); err != nil {
+		return err
+	}
+	defer os.Remove(snapshot)
+
+	if !opts.Gzip && opts.Passphrase == nil {
+		if err := os.Chmod(snapshot, 0o600); err != nil {
+			return err
+		}
+		return os.Rename(snapshot, path)
+	}
+
+	partial := path + ".partial"
+	if err := writeEncoded(snapshot, partial, opts); err != nil {
+		_ = os.Remove(partial)
+		return err
+	}
+	return os.Rename(partial, path)
+}
+
+func writeEncoded(src string, dst string, opts Options) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var w io.Writer = out
+	var closers []io.Closer
+	if opts.Passphrase != nil {
+		enc, err := newEncryptWriter(w, opts.Passphrase)
+		if err != nil {
+			return err
+		}
+		w = enc
+		closers = append(closers, enc)
+	}
+	if opts.Gzip {
+		zw := gzip.NewWriter(w)
+		w = zw
+		closers = append(closers, zw)
+	}
+
+	if _, err := io.Copy(w, in); err != nil {
+		return fmt.Errorf("write backup: %w", err)
+	}
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].Close(); err != nil {
+			return fmt.Errorf("write backup: %w", err)
+		}
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+type RestoreResult struct {
+	SchemaVersion int    `json:"schema_version"`
+	Previous      string `json:"previous,omitempty"`
+}
+
+// Restore decodes the backup at src, verifies its integrity and schema
+// version, keeps a snapshot of the current database next to it and only then
+// moves the backup into place at dbPath. The server must not be running.
+func Restore(ctx context.Context, src string, dbPath string, passphrase []byte) (RestoreResult, error) {
+	var result RestoreResult
+
+	staging := dbPath + ".restore"
+	_ = os.Remove(staging)
+	if err := decodeTo(src, staging, passphrase); err != nil {
+		_ = os.Remove(staging)
+		return result, err
+	}
+
+	version, err := db.Verify(ctx, staging)
+	if err != nil {
+		_ = os.Remove(staging)
+		return result, err
+	}
+	result.SchemaVersion = version
+
+	if _, err := os.Stat(dbPath); err == nil {
+		result.Previous = fmt.Sprintf("%s.pre-restore-%s", dbPath, time.Now().UTC().Format("20060102-150405"))
+		if err := preserve(ctx, dbPath, result.Previous); err != nil {
+			_ = os.Remove(staging)
+			if errors.Is(err, db.ErrInUse) {
+				return result, fmt.Errorf("%w; stop the server before restoring", err)
+			}
+			return result, fmt.Errorf("keep current database: %w", err)
+		}
+	}
+
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return result, err
+		}
+	}
+	if err := os.Rename(staging, dbPath); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// preserve snapshots the current database, falling back to moving the files
+// when it cannot be opened. It refuses with db.ErrInUse while another
+// process has the database open, since the restore would discard what that
+// process has in the WAL. Closing the only connection checkpoints the WAL.
+func preserve(ctx context.Context, dbPath string, dst string) error {
+	current, err := db.OpenExclusive(ctx, dbPath)
+	if errors.Is(err, db.ErrInUse) {
+		return err
+	}
+	if err == nil {
+		err = current.Backup(ctx, dst)
+		if closeErr := current.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			return nil
+		}
+	}
+
+	for _, suffix := range []string{"", "-wal"} {
+		if renameErr := os.Rename(dbPath+suffix, dst+suffix); renameErr != nil && !errors.Is(renameErr, os.ErrNotExist) {
+			return renameErr
+		}
+	}
+	return nil
+}
+
+func decodeTo(src string, dst string, passphrase []byte) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	r := bufio.NewReader(in)
+	if ok, err := hasPrefix(r, encryptedMagic); err != nil {
+		return err
+	} else if ok {
+		dec, err := newDecryptReader(r, passphrase)
+		if err != nil {
+			return err
+		}
+		r = bufio.NewReader(dec)
+	}
+	if ok, err := hasPrefix(r, gzipMagic); err != nil {
+		return err
+	} else if ok {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("read backup: %w", err)
+		}
+		defer zr.Close()
+		r = bufio.NewReader(zr)
+	}
+	if ok, err := hasPrefix(r, sqliteHeader); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("%w: %s is not a SQLite database", db.ErrInvalidBackup, src)
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, r); err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func hasPrefix(r *bufio.Reader, prefix string) (bool, error) {
+	peeked, err := r.Peek(len(prefix))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return bytes.Equal(peeked, []byte(prefix)), nil
+}
+
+// Rotate deletes all but the newest keep scheduled backups in dir.
+func Rotate(dir string, keep int) ([]string, error) {
+	names, err := scheduledBackups(dir)
+	if err != nil || keep <= 0 || len(names) <= keep {
+		return nil, err
+	}
+
+	removed := make([]string, 0, len(names)-keep)
+	for _, name := range names[:len(names)-keep] {
+		path := filepath.Join(dir, name)
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
+
+// scheduledBackups lists backup files in dir, oldest first.
+func scheduledBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, filePrefix) && strings.Contains(name, ".db") && !strings.HasSuffix(name, ".partial") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+type Schedule struct {
+	Dir      string
+	Interval time.Duration
+	Keep     int
+	Options  Options
+	Logger   *slog.Logger
+}
+
+// Run takes a backup whenever the newest one in Dir is older than Interval,
+// until ctx is done.
+func (s Schedule) Run(ctx context.Context, store *db.DB) {
+	for {
+		var wait time.Duration
+		if last, ok := s.latest(); ok {
+			wait = time.Until(last.Add(s.Interval))
+		}
+
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		path := filepath.Join(s.Dir, FileName(time.Now(), s.Options))
+		if err := Create(ctx, store, path, s.Options); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			s.Logger.Error("scheduled backup failed", "err", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(s.Interval):
+			}
+			continue
+		}
+		s.Logger.Info("scheduled backup written", "path", path)
+
+		removed, err := Rotate(s.Dir, s.Keep)
+		if err != nil {
+			s.Logger.Warn("backup rotation failed", "err", err)
+		}
+		for _, old := range removed {
+			s.Logger.Debug("removed old backup", "path", old)
+		}
+	}
+}
+
+func (s Schedule) latest() (time.Time, bool) {
+	names, err := scheduledBackups(s.Dir)
+	if err != nil || len(names) == 0 {
+		return time.Time{}, false
+	}
+	info, err := os.Stat(filepath.Join(s.Dir, names[len(names)-1]))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return info.ModTime(), true
+}
+
+// ReadPassphrase reads a passphrase from file, ignoring a trailing newline.
+func ReadPassphrase(file string) ([]byte, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read passphrase: %w", err)
+	}
+	passphrase := bytes.TrimRight(data, "\r\n")
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("passphrase file %s is empty", file)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,36 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"vcontext/internal/db"
+)
+
+func TestRestoreRefusesOpenDatabase(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "vcontext.db")
+	backupPath := filepath.Join(dir, "backup.db")
+
+	store, err := db.Open(dbPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Create(ctx, store, backupPath, Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Restore(ctx, backupPath, dbPath, nil); !errors.Is(err, db.ErrInUse) {
+		t.Fatalf("Restore with the database open = %v, want %v", err, db.ErrInUse)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Restore(ctx, backupPath, dbPath, nil); err != nil {
+		t.Fatalf("Restore after close: %v", err)
+	}
+}
@@ -0,0 +1,205 @@
+package backup
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Encrypted backups start with encryptedMagic, a random salt, the
+// PBKDF2-HMAC-SHA256 iteration count and a random nonce prefix, followed by length-prefixed
+// AES-256-GCM chunks. The additional data of the last chunk is 1, so a
+// truncated file is detected.
+const (
+	encryptedMagic  = "VCTXENC1"
+	saltSize        = 16
+	noncePrefixSize = 8
+	chunkSize       = 64 << 10
+	kdfIterations   = 600000
+	maxIterations   = 10000000
+	keySize         = 32
+)
+
+var (
+	ErrPassphraseRequired = errors.New("backup is encrypted; a passphrase is required")
+	ErrDecrypt            = errors.New("cannot decrypt backup: wrong passphrase or corrupted file")
+)
+
+type encryptWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     []byte
+}
+
+func newEncryptWriter(w io.Writer, passphrase []byte) (io.WriteCloser, error) {
+	salt := make([]byte, saltSize)
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(passphrase, salt, kdfIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, len(encryptedMagic)+saltSize+4+noncePrefixSize)
+	header = append(header, encryptedMagic...)
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, kdfIterations)
+	header = append(header, prefix...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		// Keep up to one full chunk buffered so Close can mark it final.
+		if len(e.buf) == chunkSize {
+			if err := e.seal(false); err != nil {
+				return 0, err
+			}
+		}
+		n := copy(e.buf[len(e.buf):chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+	}
+	return written, nil
+}
+
+func (e *encryptWriter) Close() error {
+	return e.seal(true)
+}
+
+func (e *encryptWriter) seal(final bool) error {
+	if e.counter == ^uint32(0) {
+		return errors.New("backup too large to encrypt")
+	}
+	sealed := e.aead.Seal(nil, nonce(e.prefix, e.counter), e.buf, chunkAD(final))
+	e.counter++
+	e.buf = e.buf[:0]
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(sealed)))
+	if _, err := e.w.Write(size[:]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+type decryptReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	pending []byte
+	done    bool
+}
+
+func newDecryptReader(r io.Reader, passphrase []byte) (io.Reader, error) {
+	header := make([]byte, len(encryptedMagic)+saltSize+4+noncePrefixSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("read encryption header: %w", err)
+	}
+	if string(header[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, errors.New("not an encrypted backup")
+	}
+	if len(passphrase) == 0 {
+		return nil, ErrPassphraseRequired
+	}
+
+	rest := header[len(encryptedMagic):]
+	salt := rest[:saltSize]
+	iterations := binary.BigEndian.Uint32(rest[saltSize:])
+	if iterations == 0 || iterations > maxIterations {
+		return nil, ErrDecrypt
+	}
+	aead, err := newAEAD(passphrase, salt, int(iterations))
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{r: r, aead: aead, prefix: rest[saltSize+4:]}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.pending) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.pending)
+	d.pending = d.pending[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	var size [4]byte
+	if _, err := io.ReadFull(d.r, size[:]); err != nil {
+		return fmt.Errorf("backup is truncated: %w", err)
+	}
+	length := binary.BigEndian.Uint32(size[:])
+	if length > chunkSize+uint32(d.aead.Overhead()) {
+		return ErrDecrypt
+	}
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return fmt.Errorf("backup is truncated: %w", err)
+	}
+
+	n := nonce(d.prefix, d.counter)
+	plain, err := d.aead.Open(nil, n, sealed, chunkAD(false))
+	if err != nil {
+		plain, err = d.aead.Open(nil, n, sealed, chunkAD(true))
+		if err != nil {
+			return ErrDecrypt
+		}
+		d.done = true
+		var extra [1]byte
+		if m, _ := d.r.Read(extra[:]); m > 0 {
+			return ErrDecrypt
+		}
+	}
+	d.counter++
+	d.pending = plain
+	return nil
+}
+
+func newAEAD(passphrase []byte, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key(passphrase, salt, iterations, keySize, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func nonce(prefix []byte, counter uint32) []byte {
+	return binary.BigEndian.AppendUint32(append([]byte(nil), prefix...), counter)
+}
+
+func chunkAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
@@ -0,0 +1,109 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+func encrypt(t *testing.T, plain []byte, passphrase string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := newEncryptWriter(&out, []byte(passphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func decrypt(data []byte, passphrase string) ([]byte, error) {
+	r, err := newDecryptReader(bytes.NewReader(data), []byte(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+// The key derivation is PBKDF2-HMAC-SHA256; existing backups depend on it.
+func TestKeyDerivation(t *testing.T) {
+	got := hex.EncodeToString(pbkdf2.Key([]byte("password"), []byte("salt"), 2, keySize, sha256.New))
+	want := "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"
+	if got != want {
+		t.Fatalf("PBKDF2-HMAC-SHA256 = %s, want %s", got, want)
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, chunkSize - 1, chunkSize, chunkSize + 1, 3*chunkSize + 17} {
+		plain := make([]byte, size)
+		if _, err := rand.Read(plain); err != nil {
+			t.Fatal(err)
+		}
+		got, err := decrypt(encrypt(t, plain, "correct horse"), "correct horse")
+		if err != nil {
+			t.Fatalf("size %d: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("size %d: round trip changed the data", size)
+		}
+	}
+}
+
+func TestEncryptTamper(t *testing.T) {
+	plain := bytes.Repeat([]byte("vcontext "), chunkSize/4)
+	sealed := encrypt(t, plain, "correct horse")
+	header := len(encryptedMagic) + saltSize + 4 + noncePrefixSize
+	firstChunk := header + 4 + chunkSize + 16
+
+	flip := func(offset int) []byte {
+		data := bytes.Clone(sealed)
+		data[offset] ^= 1
+		return data
+	}
+	// swapped moves the final chunk in front of the first one.
+	swapped := append(append(bytes.Clone(sealed[:header]), sealed[firstChunk:]...), sealed[header:firstChunk]...)
+
+	tests := []struct {
+		name       string
+		data       []byte
+		passphrase string
+	}{
+		{name: "wrong passphrase", data: sealed, passphrase: "wrong horse"},
+		{name: "flipped salt", data: flip(len(encryptedMagic))},
+		{name: "flipped nonce prefix", data: flip(header - 1)},
+		{name: "flipped ciphertext", data: flip(header + 10)},
+		{name: "flipped last byte", data: flip(len(sealed) - 1)},
+		{name: "truncated after first chunk", data: sealed[:firstChunk]},
+		{name: "truncated mid chunk", data: sealed[:len(sealed)-5]},
+		{name: "trailing data", data: append(bytes.Clone(sealed), 0)},
+		{name: "reordered chunks", data: swapped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passphrase := tt.passphrase
+			if passphrase == "" {
+				passphrase = "correct horse"
+			}
+			got, err := decrypt(tt.data, passphrase)
+			if err == nil {
+				t.Fatalf("decrypted %d bytes from a tampered backup", len(got))
+			}
+		})
+	}
+
+	if _, err := decrypt(sealed, ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Fatalf("decrypt without passphrase = %v, want %v", err, ErrPassphraseRequired)
+	}
+}
@@ -1,10 +1,89 @@
 package common
 
 import (
-	"log"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
-func NewLogger() *log.Logger {
-	return log.New(os.Stderr, "vcontext: ", log.LstdFlags|log.LUTC)
+const (
+	defaultLogFileMaxBytes = 10 * 1024 * 1024
+	defaultLogFileBackups  = 3
+)
+
+type LogOptions struct {
+	Level  string
+	Format string
+	File   string
+}
+
+func LogOptionsFromEnv() LogOptions {
+	return LogOptions{
+		Level:  strings.TrimSpace(os.Getenv("VCONTEXT_LOG_LEVEL")),
+		Format: strings.TrimSpace(os.Getenv("VCONTEXT_LOG_FORMAT")),
+		File:   strings.TrimSpace(os.Getenv("VCONTEXT_LOG_FILE")),
+	}
+}
+
+func NewLogger(opts LogOptions) *slog.Logger {
+	var out io.Writer = os.Stderr
+	var fileErr error
+	if path := ResolveLogFile(opts.File); path != "" {
+		file, err := NewRotatingFile(path, defaultLogFileMaxBytes, defaultLogFileBackups)
+		if err != nil {
+			fileErr = err
+		} else {
+			out = io.MultiWriter(os.Stderr, file)
+		}
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: ParseLogLevel(opts.Level)}
+	var handler slog.Handler
+	if strings.EqualFold(opts.Format, "json") {
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	} else {
+		handler = slog.NewTextHandler(out, handlerOpts)
+	}
+
+	logger := slog.New(handler)
+	if fileErr != nil {
+		logger.Warn("log file disabled", "err", fileErr)
+	}
+	return logger
+}
+
+func NewDiscardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func ParseLogLevel(value string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func ResolveLogFile(value string) string {
+	value = strings.TrimSpace(value)
+	if value != "default" {
+		return value
+	}
+	dir, err := ConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "logs", "vcontext.log")
+}
+
+func Fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
 }
@@ -0,0 +1,18 @@
+package common
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+func ConfigDir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	if configDir == "" {
+		return "", errors.New("config dir not available")
+	}
+	return filepath.Join(configDir, "vcontext"), nil
+}
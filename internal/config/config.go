@@ -0,0 +1,346 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"vcontext/internal/common"
+)
+
+const (
+	FileName     = "config.toml"
+	JSONFileName = "config.json"
+	ProjectFile  = ".vcontext.toml"
+)
+
+// Layers in increasing precedence. Command line flags override all of them
+// and are applied by the commands themselves.
+const (
+	LayerDefault = "default"
+	LayerUser    = "user"
+	LayerProject = "project"
+	LayerEnv     = "env"
+)
+
+type Config struct {
+	DBPath string
+	Memory Memory
+	Update Update
+	Tools  Tools
+	Server Server
+	Backup Backup
+
+	sources map[string]Source
+}
+
+// Memory holds defaults for the items of one project.
+type Memory struct {
+	// Namespace is used by save and search requests that do not name one.
+	Namespace string
+	// Tags are added to every saved item.
+	Tags []string
+	// Retention deletes items older than this; zero keeps them forever.
+	Retention Duration
+}
+
+type Update struct {
+	Repo    string
+	Channel string
+	Source  string
+	URL     string
+	Check   bool
+}
+
+type Tools struct {
+	DefaultTopK       int
+	MaxTopK           int
+	DefaultImportance int
+}
+
+type Server struct {
+	MaxMessageBytes int
+}
+
+type Backup struct {
+	// Dir holds scheduled backups; empty means <config dir>/backups.
+	Dir string
+	// Interval enables scheduled backups while the server runs.
+	Interval Duration
+	Keep     int
+	Gzip     bool
+	// PassphraseFile enables encryption with the passphrase read from it.
+	PassphraseFile string
+}
+
+// Source tells where the value of a key came from. Path is the file or
+// environment variable; it is empty for defaults.
+type Source struct {
+	Layer string `json:"layer"`
+	Path  string `json:"path,omitempty"`
+}
+
+func (s Source) String() string {
+	if s.Path == "" {
+		return s.Layer
+	}
+	return s.Layer + " " + s.Path
+}
+
+type Duration struct {
+	time.Duration
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := parseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
+}
+
+// parseDuration accepts Go durations and whole days such as "90d".
+func parseDuration(text string) (time.Duration, error) {
+	text = strings.TrimSpace(text)
+	if days, ok := strings.CutSuffix(text, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	return time.ParseDuration(text)
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// KeyError reports an invalid setting, naming the file or environment
+// variable and the key.
+type KeyError struct {
+	Path string
+	Key  string
+	Err  error
+}
+
+func (e *KeyError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Path, e.Key, e.Err)
+}
+
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+func Default() Config {
+	return Config{
+		Update: Update{Repo: "vietrix/vcontext", Channel: "stable", Source: "github", Check: true},
+		Tools: Tools{
+			DefaultTopK:       5,
+			MaxTopK:           50,
+			DefaultImportance: 3,
+		},
+		Server: Server{MaxMessageBytes: 8 * 1024 * 1024},
+		Backup: Backup{Keep: 7},
+	}
+}
+
+// Path returns the user config file: config.toml in the config directory,
+// or config.json when only that one exists.
+func Path() (string, error) {
+	dir, err := common.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, FileName)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(filepath.Join(dir, JSONFileName)); err == nil {
+			return filepath.Join(dir, JSONFileName), nil
+		}
+	}
+	return path, nil
+}
+
+// ProjectPath finds the project config file by walking up from the working
+// directory. The search ends at the root of the enclosing git repository,
+// so a file outside the repository is never picked up. It returns "" when
+// there is none.
+func ProjectPath() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ProjectFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// Load layers the user config, the project config and the environment over
+// the defaults. Missing files are skipped.
+func Load() (Config, error) {
+	cfg := Default()
+
+	if path, err := Path(); err == nil {
+		if err := cfg.loadFile(path, LayerUser); err != nil {
+			return cfg, err
+		}
+	}
+	if path := ProjectPath(); path != "" {
+		if err := cfg.loadFile(path, LayerProject); err != nil {
+			return cfg, err
+		}
+	}
+	if err := cfg.loadEnv(); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.validate()
+}
+
+// Source returns where key got its effective value.
+func (c *Config) Source(key string) Source {
+	if source, ok := c.sources[key]; ok {
+		return source
+	}
+	return Source{Layer: LayerDefault}
+}
+
+func (c *Config) loadFile(path string, layer string) error {
+	values, err := readFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return c.apply(values, path, layer)
+}
+
+func (c *Config) apply(values map[string]any, path string, layer string) error {
+	flat := map[string]any{}
+	flatten("", values, flat)
+	names := make([]string, 0, len(flat))
+	for name := range flat {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		key, ok := Lookup(name)
+		if !ok {
+			return &KeyError{Path: path, Key: name, Err: errors.New("unknown key")}
+		}
+		if layer == LayerProject && !key.project {
+			return &KeyError{Path: path, Key: name, Err: errors.New("only the user config may set this key")}
+		}
+		if err := key.set(c, flat[name]); err != nil {
+			return &KeyError{Path: path, Key: name, Err: err}
+		}
+		if key.path {
+			key.resolvePath(c, filepath.Dir(path))
+		}
+		c.setSource(name, Source{Layer: layer, Path: path})
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	for _, key := range keys {
+		if key.Env == "" {
+			continue
+		}
+		value := strings.TrimSpace(os.Getenv(key.Env))
+		if value == "" {
+			continue
+		}
+		if err := key.set(c, value); err != nil {
+			return &KeyError{Path: "$" + key.Env, Key: key.Name, Err: err}
+		}
+		c.setSource(key.Name, Source{Layer: LayerEnv, Path: "$" + key.Env})
+	}
+	return nil
+}
+
+func (c *Config) setSource(key string, source Source) {
+	if c.sources == nil {
+		c.sources = map[string]Source{}
+	}
+	c.sources[key] = source
+}
+
+func (c *Config) validate() error {
+	for _, key := range keys {
+		if key.check == nil {
+			continue
+		}
+		if err := key.check(c); err != nil {
+			return &KeyError{Path: c.Source(key.Name).Path, Key: key.Name, Err: err}
+		}
+	}
+	return nil
+}
+
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]any{}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if err := json.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+		return values, nil
+	}
+	if _, err := toml.Decode(string(data), &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func flatten(prefix string, values map[string]any, out map[string]any) {
+	for name, value := range values {
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if table, ok := value.(map[string]any); ok {
+			flatten(name, table, out)
+			continue
+		}
+		out[name] = value
+	}
+}
+
+// BackupDir resolves the directory for scheduled backups.
+func (b Backup) BackupDir() (string, error) {
+	if b.Dir != "" {
+		return b.Dir, nil
+	}
+	dir, err := common.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "backups"), nil
+}
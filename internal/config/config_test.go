@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProjectLayerKeys(t *testing.T) {
+	for _, tt := range []struct {
+		values map[string]any
+		wantOK bool
+	}{
+		{values: map[string]any{"db_path": "project.db", "memory": map[string]any{"namespace": "proj"}}, wantOK: true},
+		{values: map[string]any{"update": map[string]any{"url": "https://example.com/api/v3"}}},
+		{values: map[string]any{"backup": map[string]any{"dir": "/tmp/backups"}}},
+		{values: map[string]any{"server": map[string]any{"max_message_bytes": int64(1 << 20)}}},
+	} {
+		cfg := Default()
+		err := cfg.apply(tt.values, "/repo/.vcontext.toml", LayerProject)
+		if tt.wantOK {
+			if err != nil {
+				t.Errorf("apply(%v): %v", tt.values, err)
+			}
+			continue
+		}
+		var keyErr *KeyError
+		if !errors.As(err, &keyErr) || keyErr.Path != "/repo/.vcontext.toml" {
+			t.Errorf("apply(%v) = %v, want a KeyError naming the project file", tt.values, err)
+		}
+	}
+
+	// The user config may set every key.
+	cfg := Default()
+	if err := cfg.apply(map[string]any{"update": map[string]any{"url": "https://example.com/api/v3"}}, "/home/config.toml", LayerUser); err != nil {
+		t.Errorf("user apply: %v", err)
+	}
+}
+
+func TestProjectRetentionNeedsNamespace(t *testing.T) {
+	retention := map[string]any{"retention": "30d"}
+	for _, tt := range []struct {
+		user    map[string]any
+		project map[string]any
+		wantOK  bool
+	}{
+		{project: map[string]any{"memory": retention}},
+		{user: map[string]any{"memory": map[string]any{"namespace": "global"}}, project: map[string]any{"memory": retention}},
+		{project: map[string]any{"memory": map[string]any{"retention": "30d", "namespace": "proj"}}, wantOK: true},
+		{user: map[string]any{"memory": retention}, wantOK: true},
+	} {
+		cfg := Default()
+		if tt.user != nil {
+			if err := cfg.apply(tt.user, "/home/config.toml", LayerUser); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if tt.project != nil {
+			if err := cfg.apply(tt.project, "/repo/.vcontext.toml", LayerProject); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := cfg.validate(); (err == nil) != tt.wantOK {
+			t.Errorf("user %v, project %v: validate() = %v, want ok %v", tt.user, tt.project, err, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,347 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Key describes one setting. Name is its dotted path in the config file.
+type Key struct {
+	Name string
+	Env  string
+	Doc  string
+
+	field func(c *Config) any
+	check func(c *Config) error
+	// path marks file names; relative ones are resolved against the
+	// directory of the config file that sets them.
+	path bool
+	// project marks the keys a project file may set. The others redirect
+	// updates, backups or the server and are left to the user.
+	project bool
+}
+
+var keys = []Key{
+	{
+		Name:    "db_path",
+		Env:     "VCONTEXT_DB_PATH",
+		Doc:     "SQLite database file; empty means vcontext.db in the config directory",
+		field:   func(c *Config) any { return &c.DBPath },
+		path:    true,
+		project: true,
+	},
+	{
+		Name:    "memory.namespace",
+		Env:     "VCONTEXT_NAMESPACE",
+		Doc:     "namespace of save and search requests that do not name one",
+		field:   func(c *Config) any { return &c.Memory.Namespace },
+		project: true,
+	},
+	{
+		Name:    "memory.tags",
+		Doc:     "tags added to every saved item",
+		field:   func(c *Config) any { return &c.Memory.Tags },
+		project: true,
+	},
+	{
+		Name:    "memory.retention",
+		Doc:     "delete items older than this, such as \"90d\"; 0 keeps them forever",
+		field:   func(c *Config) any { return &c.Memory.Retention },
+		project: true,
+		check: func(c *Config) error {
+			if c.Memory.Retention.Duration < 0 {
+				return errors.New("must not be negative")
+			}
+			// Retention runs against the shared database, so a project may
+			// only expire the items of its own namespace.
+			if c.Memory.Retention.Duration > 0 && c.Source("memory.retention").Layer == LayerProject {
+				namespace := c.Source("memory.namespace").Layer
+				if c.Memory.Namespace == "" || namespace != LayerProject && namespace != LayerEnv {
+					return errors.New("a project config that sets it must also set memory.namespace")
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Name:  "update.repo",
+		Env:   "VCONTEXT_UPDATE_REPO",
+		Doc:   "GitHub repository (org/name) that vcontext update installs from",
+		field: func(c *Config) any { return &c.Update.Repo },
+		check: func(c *Config) error {
+			if owner, name, ok := strings.Cut(c.Update.Repo, "/"); !ok || owner == "" || name == "" {
+				return errors.New("must have the form org/name")
+			}
+			return nil
+		},
+	},
+	{
+		Name:  "update.channel",
+		Env:   "VCONTEXT_UPDATE_CHANNEL",
+		Doc:   "releases vcontext update considers: stable, or prerelease to include pre-releases",
+		field: func(c *Config) any { return &c.Update.Channel },
+		check: func(c *Config) error {
+			if c.Update.Channel != "stable" && c.Update.Channel != "prerelease" {
+				return fmt.Errorf("must be stable or prerelease, got %q", c.Update.Channel)
+			}
+			return nil
+		},
+	},
+	{
+		Name:  "update.source",
+		Env:   "VCONTEXT_UPDATE_SOURCE",
+		Doc:   "where vcontext update finds releases: github, manifest or dir",
+		field: func(c *Config) any { return &c.Update.Source },
+		check: func(c *Config) error {
+			switch c.Update.Source {
+			case "github":
+				return nil
+			case "manifest", "dir":
+				if c.Update.URL == "" {
+					return fmt.Errorf("%s needs update.url", c.Update.Source)
+				}
+				return nil
+			}
+			return fmt.Errorf("must be github, manifest or dir, got %q", c.Update.Source)
+		},
+	},
+	{
+		Name:  "update.url",
+		Env:   "VCONTEXT_UPDATE_URL",
+		Doc:   "API base URL for github (empty means github.com), manifest URL, or release directory",
+		field: func(c *Config) any { return &c.Update.URL },
+	},
+	{
+		Name:  "update.check",
+		Env:   "VCONTEXT_UPDATE_CHECK",
+		Doc:   "check for a newer release once a day and mention it in version, doctor and the server instructions",
+		field: func(c *Config) any { return &c.Update.Check },
+	},
+	{
+		Name:  "tools.default_top_k",
+		Doc:   "search results returned when top_k is not given",
+		field: func(c *Config) any { return &c.Tools.DefaultTopK },
+		check: func(c *Config) error {
+			if c.Tools.DefaultTopK < 1 || c.Tools.DefaultTopK > c.Tools.MaxTopK {
+				return fmt.Errorf("must be between 1 and tools.max_top_k (%d)", c.Tools.MaxTopK)
+			}
+			return nil
+		},
+	},
+	{
+		Name:  "tools.max_top_k",
+		Doc:   "upper bound for top_k",
+		field: func(c *Config) any { return &c.Tools.MaxTopK },
+		check: intRange(func(c *Config) int { return c.Tools.MaxTopK }, 1, 1000),
+	},
+	{
+		Name:  "tools.default_importance",
+		Doc:   "importance of saved items that do not set one",
+		field: func(c *Config) any { return &c.Tools.DefaultImportance },
+		check: intRange(func(c *Config) int { return c.Tools.DefaultImportance }, 1, 5),
+	},
+	{
+		Name:  "server.max_message_bytes",
+		Doc:   "largest JSON-RPC message the server accepts",
+		field: func(c *Config) any { return &c.Server.MaxMessageBytes },
+		check: intRange(func(c *Config) int { return c.Server.MaxMessageBytes }, 64*1024, 1024*1024*1024),
+	},
+	{
+		Name:  "backup.dir",
+		Doc:   "directory for scheduled backups; empty means backups/ in the config directory",
+		field: func(c *Config) any { return &c.Backup.Dir },
+		path:  true,
+	},
+	{
+		Name:  "backup.interval",
+		Doc:   "take a scheduled backup when the newest one is older than this; 0 disables it",
+		field: func(c *Config) any { return &c.Backup.Interval },
+		check: func(c *Config) error {
+			if c.Backup.Interval.Duration < 0 {
+				return errors.New("must not be negative")
+			}
+			return nil
+		},
+	},
+	{
+		Name:  "backup.keep",
+		Doc:   "number of scheduled backups to keep",
+		field: func(c *Config) any { return &c.Backup.Keep },
+		check: intRange(func(c *Config) int { return c.Backup.Keep }, 1, 10000),
+	},
+	{
+		Name:  "backup.gzip",
+		Doc:   "compress scheduled backups",
+		field: func(c *Config) any { return &c.Backup.Gzip },
+	},
+	{
+		Name:  "backup.passphrase_file",
+		Doc:   "encrypt scheduled backups with the passphrase in this file",
+		field: func(c *Config) any { return &c.Backup.PassphraseFile },
+		path:  true,
+	},
+}
+
+// Keys lists every setting in the order of the documentation.
+func Keys() []Key {
+	return append([]Key(nil), keys...)
+}
+
+func Lookup(name string) (Key, bool) {
+	for _, key := range keys {
+		if key.Name == name {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// Get returns the value of the key in c.
+func (k Key) Get(c *Config) any {
+	switch field := k.field(c).(type) {
+	case *string:
+		return *field
+	case *int:
+		return *field
+	case *bool:
+		return *field
+	case *Duration:
+		return *field
+	case *[]string:
+		return append([]string{}, *field...)
+	}
+	return nil
+}
+
+func (k Key) resolvePath(c *Config, dir string) {
+	if field, ok := k.field(c).(*string); ok && *field != "" && !filepath.IsAbs(*field) {
+		*field = filepath.Join(dir, *field)
+	}
+}
+
+// set assigns a value decoded from a config file, or the text of an
+// environment variable or command line argument.
+func (k Key) set(c *Config, raw any) error {
+	switch field := k.field(c).(type) {
+	case *string:
+		value, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("must be a string, got %T", raw)
+		}
+		*field = strings.TrimSpace(value)
+	case *int:
+		value, err := toInt(raw)
+		if err != nil {
+			return err
+		}
+		*field = value
+	case *bool:
+		switch value := raw.(type) {
+		case bool:
+			*field = value
+		case string:
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("must be true or false, got %q", value)
+			}
+			*field = parsed
+		default:
+			return fmt.Errorf("must be a boolean, got %T", raw)
+		}
+	case *Duration:
+		value, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("must be a duration such as \"24h\" or \"30d\", got %T", raw)
+		}
+		parsed, err := parseDuration(value)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as \"24h\" or \"30d\", got %q", value)
+		}
+		field.Duration = parsed
+	case *[]string:
+		values, err := toStrings(raw)
+		if err != nil {
+			return err
+		}
+		*field = values
+	}
+	return nil
+}
+
+// fileValue converts text from the command line to the value stored in a
+// config file.
+func (k Key) fileValue(text string) (any, error) {
+	scratch := Default()
+	if err := k.set(&scratch, text); err != nil {
+		return nil, err
+	}
+	switch value := k.Get(&scratch).(type) {
+	case int:
+		return int64(value), nil
+	case Duration:
+		return strings.TrimSpace(text), nil
+	default:
+		return value, nil
+	}
+}
+
+func toInt(raw any) (int, error) {
+	switch value := raw.(type) {
+	case int64:
+		return int(value), nil
+	case float64:
+		if value != math.Trunc(value) || math.Abs(value) > math.MaxInt32 {
+			return 0, fmt.Errorf("must be an integer, got %v", value)
+		}
+		return int(value), nil
+	case string:
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, fmt.Errorf("must be an integer, got %q", value)
+		}
+		return parsed, nil
+	}
+	return 0, fmt.Errorf("must be an integer, got %T", raw)
+}
+
+// toStrings accepts an array of strings or comma separated text.
+func toStrings(raw any) ([]string, error) {
+	var values []string
+	switch raw := raw.(type) {
+	case string:
+		values = strings.Split(raw, ",")
+	case []string:
+		values = raw
+	case []any:
+		for _, item := range raw {
+			value, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("must be a list of strings, got %T in it", item)
+			}
+			values = append(values, value)
+		}
+	default:
+		return nil, fmt.Errorf("must be a list of strings, got %T", raw)
+	}
+
+	list := make([]string, 0, len(values))
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			list = append(list, value)
+		}
+	}
+	return list, nil
+}
+
+func intRange(value func(c *Config) int, min int, max int) func(c *Config) error {
+	return func(c *Config) error {
+		if v := value(c); v < min || v > max {
+			return fmt.Errorf("must be between %d and %d, got %d", min, max, v)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Set stores key in the config file at path, creating the file if needed.
+// layer is LayerUser or LayerProject. The file is checked as a whole before
+// it is replaced, so an invalid value never reaches disk. Comments in the
+// file are not preserved.
+func Set(path string, layer string, name string, text string) error {
+	key, ok := Lookup(name)
+	if !ok {
+		return &KeyError{Key: name, Err: errors.New("unknown key")}
+	}
+	if layer == LayerProject && !key.project {
+		return &KeyError{Path: path, Key: name, Err: errors.New("only the user config may set this key")}
+	}
+	value, err := key.fileValue(text)
+	if err != nil {
+		return &KeyError{Key: name, Err: err}
+	}
+
+	values, err := readFile(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		values = map[string]any{}
+	}
+	setNested(values, strings.Split(name, "."), value)
+
+	check := Default()
+	if err := check.apply(values, path, layer); err != nil {
+		return err
+	}
+	if err := check.validate(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	} else if err := toml.NewEncoder(&buf).Encode(values); err != nil {
+		return err
+	}
+
+	return writeFile(path, buf.Bytes())
+}
+
+func setNested(values map[string]any, path []string, value any) {
+	for _, part := range path[:len(path)-1] {
+		table, ok := values[part].(map[string]any)
+		if !ok {
+			table = map[string]any{}
+			values[part] = table
+		}
+		values = table
+	}
+	values[path[len(path)-1]] = value
+}
+
+func writeFile(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
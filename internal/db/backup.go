@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// ErrInUse means another connection, such as a running server, has the
+// database open.
+var ErrInUse = errors.New("database is in use")
+
+// OpenExclusive opens the existing database at path for sole use. It fails
+// with ErrInUse while any other connection has the database open, and keeps
+// new ones out until Close. It does not apply the schema or migrations.
+func OpenExclusive(ctx context.Context, path string) (*DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+
+	// In exclusive locking mode the lock taken by BEGIN EXCLUSIVE is held
+	// until the connection closes. Connections of other processes hold a
+	// shared lock on a WAL database for as long as they are open, even
+	// when idle, so this fails at once while a server is running.
+	for _, statement := range []string{"PRAGMA busy_timeout=0", "PRAGMA locking_mode=EXCLUSIVE", "BEGIN EXCLUSIVE", "COMMIT"} {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			_ = conn.Close()
+			var sqliteErr *sqlite.Error
+			if errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY {
+				return nil, ErrInUse
+			}
+			return nil, fmt.Errorf("lock database: %w", err)
+		}
+	}
+	return &DB{conn: conn, logger: slog.New(slog.NewTextHandler(io.Discard, nil))}, nil
+}
+
+// Backup writes a consistent snapshot of the database to path with
+// VACUUM INTO, which is safe while other connections are writing.
+func (d *DB) Backup(ctx context.Context, path string) error {
+	if _, err := d.conn.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	return nil
+}
+
+// Verify opens the database file at path read-only and checks that it is
+// intact and that this binary understands its schema. It returns the schema
+// version.
+func Verify(ctx context.Context, path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+
+	conn, err := sql.Open("sqlite", readOnlyURI(path))
+	if err != nil {
+		return 0, fmt.Errorf("open sqlite: %w", err)
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRowContext(ctx, `PRAGMA integrity_check`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("%w: integrity check: %s", ErrInvalidBackup, result)
+	}
+
+	var tables int
+	if err := conn.QueryRowContext(
+		ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('context_items', 'context_items_fts')`,
+	).Scan(&tables); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if tables != 2 {
+		return 0, fmt.Errorf("%w: not a vcontext database", ErrInvalidBackup)
+	}
+
+	version, err := userVersion(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if version > SchemaVersion() {
+		return version, fmt.Errorf("%w: schema version %d is newer than this binary supports (%d)", ErrInvalidBackup, version, SchemaVersion())
+	}
+	return version, nil
+}
+
+// FileSchemaVersion reads the schema version of the database file at path
+// over a read-only connection, so unlike Open it neither creates nor
+// migrates the file.
+func FileSchemaVersion(ctx context.Context, path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+
+	conn, err := sql.Open("sqlite", readOnlyURI(path))
+	if err != nil {
+		return 0, fmt.Errorf("open sqlite: %w", err)
+	}
+	defer conn.Close()
+	return userVersion(ctx, conn)
+}
+
+// readOnlyURI builds a read-only SQLite URI for path, escaping characters
+// such as "?" and "#" that would otherwise end the file name.
+func readOnlyURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows: file:///C:/dir/vcontext.db
+		path = "/" + path
+	}
+	uri := url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}
+	return uri.String()
+}
@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVerifyEscapesPath(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	plain := filepath.Join(dir, "plain.db")
+	store, err := Open(plain, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// A naive URI would open "weird" and read the rest as query and fragment.
+	path := filepath.Join(dir, "weird?x#y%20.db")
+	if err := os.Rename(plain, path); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := Verify(ctx, path)
+	if err != nil {
+		t.Fatalf("Verify(%q): %v", path, err)
+	}
+	if version != SchemaVersion() {
+		t.Fatalf("Verify = %d, want %d", version, SchemaVersion())
+	}
+}
+
+func TestFileSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vcontext.db")
+	if _, err := FileSchemaVersion(ctx, path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("FileSchemaVersion of a missing file = %v, want %v", err, os.ErrNotExist)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("FileSchemaVersion created the file: %v", err)
+	}
+
+	store, err := Open(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.conn.ExecContext(ctx, `PRAGMA user_version = 1`); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		version, err := FileSchemaVersion(ctx, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if version != 1 {
+			t.Fatalf("FileSchemaVersion = %d, want 1: the file must not be migrated", version)
+		}
+	}
+}
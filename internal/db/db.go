@@ -2,14 +2,17 @@ package db
 
 import (
 	"context"
+	"crypto/sha256"
 	"database/sql"
 	_ "embed"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"strings"
+	"unicode"
 
 	_ "modernc.org/sqlite"
 )
@@ -17,16 +20,19 @@ import (
 //go:embed schema.sql
 var schemaSQL string
 
-var ErrNotFound = errors.New("context item not found")
+var (
+	ErrNotFound     = errors.New("context item not found")
+	ErrInvalidQuery = errors.New("invalid search query")
+)
 
 type DB struct {
 	conn   *sql.DB
-	logger *log.Logger
+	logger *slog.Logger
 }
 
-func Open(path string, logger *log.Logger) (*DB, error) {
+func Open(path string, logger *slog.Logger) (*DB, error) {
 	if logger == nil {
-		logger = log.New(io.Discard, "", 0)
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
 	}
 
 	conn, err := sql.Open("sqlite", path)
@@ -54,6 +60,11 @@ func Open(path string, logger *log.Logger) (*DB, error) {
 		return nil, fmt.Errorf("apply schema: %w", err)
 	}
 
+	if err := migrate(context.Background(), conn, logger); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
 	return &DB{conn: conn, logger: logger}, nil
 }
 
@@ -70,15 +81,17 @@ func (d *DB) InsertContext(ctx context.Context, item ContextItem) error {
 	_, err = d.conn.ExecContext(
 		ctx,
 		`INSERT INTO context_items (
-			id, created_at, source, thread_id, role, title, content, tags, importance
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, created_at, namespace, source, thread_id, role, title, content, content_hash, tags, importance
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		item.ID,
 		item.CreatedAt,
+		item.Namespace,
 		item.Source,
 		item.ThreadID,
 		item.Role,
 		item.Title,
 		item.Content,
+		ContentHash(item.Content),
 		tagsJSON,
 		item.Importance,
 	)
@@ -89,68 +102,291 @@ func (d *DB) InsertContext(ctx context.Context, item ContextItem) error {
 	return nil
 }
 
-func (d *DB) GetContext(ctx context.Context, id string) (*ContextItem, error) {
-	var item ContextItem
-	var source sql.NullString
-	var threadID sql.NullString
-	var role sql.NullString
-	var title sql.NullString
-	var tags sql.NullString
+func (d *DB) InsertContexts(ctx context.Context, items []ContextItem) error {
+	tx, err := d.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin insert: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
+	if err := insertContexts(ctx, tx, items); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit insert: %w", err)
+	}
+
+	return nil
+}
+
+func insertContexts(ctx context.Context, tx *sql.Tx, items []ContextItem) error {
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO context_items (
+		id, created_at, namespace, source, thread_id, role, title, content, content_hash, tags, importance
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("prepare insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, item := range items {
+		tagsJSON, err := encodeTags(item.Tags)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(
+			ctx,
+			item.ID,
+			item.CreatedAt,
+			item.Namespace,
+			item.Source,
+			item.ThreadID,
+			item.Role,
+			item.Title,
+			item.Content,
+			ContentHash(item.Content),
+			tagsJSON,
+			item.Importance,
+		); err != nil {
+			return fmt.Errorf("insert context: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (d *DB) GetContext(ctx context.Context, id string) (*ContextItem, error) {
 	row := d.conn.QueryRowContext(
 		ctx,
-		`SELECT id, created_at, source, thread_id, role, title, content, tags, importance
+		`SELECT `+contextItemColumns+`
 		 FROM context_items WHERE id = ?`,
 		id,
 	)
 
-	if err := row.Scan(
-		&item.ID,
-		&item.CreatedAt,
-		&source,
-		&threadID,
-		&role,
-		&title,
-		&item.Content,
-		&tags,
-		&item.Importance,
-	); err != nil {
+	item, err := scanContextItem(row)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNotFound
 		}
 		return nil, fmt.Errorf("get context: %w", err)
 	}
 
-	item.Source = nullStringPtr(source)
-	item.ThreadID = nullStringPtr(threadID)
-	item.Role = nullStringPtr(role)
-	item.Title = nullStringPtr(title)
+	return item, nil
+}
 
-	parsedTags, err := decodeTags(tags)
+func (d *DB) ContextExists(ctx context.Context, id string) (bool, error) {
+	var exists bool
+	if err := d.conn.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM context_items WHERE id = ?)`, id).Scan(&exists); err != nil {
+		return false, fmt.Errorf("check context: %w", err)
+	}
+	return exists, nil
+}
+
+// HasContent reports whether namespace already holds an item whose content
+// hashes to hash (see ContentHash).
+func (d *DB) HasContent(ctx context.Context, namespace *string, hash string) (bool, error) {
+	var exists bool
+	if err := d.conn.QueryRowContext(
+		ctx,
+		`SELECT EXISTS (SELECT 1 FROM context_items WHERE content_hash = ? AND namespace IS ?)`,
+		hash,
+		namespace,
+	).Scan(&exists); err != nil {
+		return false, fmt.Errorf("check content: %w", err)
+	}
+	return exists, nil
+}
+
+func (d *DB) RecentContext(ctx context.Context, threadID *string, limit int) ([]ContextItem, error) {
+	return d.ListContext(ctx, ListFilter{ThreadID: threadID, Limit: limit})
+}
+
+func (d *DB) ListContext(ctx context.Context, filter ListFilter) ([]ContextItem, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 20
+	}
+
+	where, args := filter.where()
+	query := `SELECT ` + contextItemColumns + ` FROM context_items` + where +
+		` ORDER BY created_at DESC, rowid DESC LIMIT ? OFFSET ?`
+	args = append(args, limit, filter.Offset)
+
+	rows, err := d.conn.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("list context: %w", err)
 	}
-	item.Tags = parsedTags
+	defer rows.Close()
 
-	return &item, nil
+	items := make([]ContextItem, 0, limit)
+	for rows.Next() {
+		item, err := scanContextItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan context item: %w", err)
+		}
+		items = append(items, *item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate context items: %w", err)
+	}
+
+	return items, nil
 }
 
-func (d *DB) SearchContext(ctx context.Context, query string, topK int, threadID *string, minImportance int) ([]SearchResult, error) {
+// EachContext streams every item matching filter to fn without loading the
+// result set into memory. Limit and Offset are ignored. Items are ordered by
+// creation time, or grouped by thread when byThread is set. fn must not use
+// the store: the single connection is busy until EachContext returns.
+func (d *DB) EachContext(ctx context.Context, filter ListFilter, byThread bool, fn func(ContextItem) error) error {
+	where, args := filter.where()
+	order := ` ORDER BY created_at, rowid`
+	if byThread {
+		order = ` ORDER BY thread_id IS NULL, thread_id, created_at, rowid`
+	}
+
+	rows, err := d.conn.QueryContext(ctx, `SELECT `+contextItemColumns+` FROM context_items`+where+order, args...)
+	if err != nil {
+		return fmt.Errorf("list context: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanContextItem(rows)
+		if err != nil {
+			return fmt.Errorf("scan context item: %w", err)
+		}
+		if err := fn(*item); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate context items: %w", err)
+	}
+
+	return nil
+}
+
+func (f ListFilter) where() (string, []any) {
+	builder := strings.Builder{}
+	builder.WriteString(` WHERE 1 = 1`)
+
+	args := []any{}
+	if f.Namespace != nil {
+		builder.WriteString(" AND namespace = ?")
+		args = append(args, *f.Namespace)
+	}
+	if f.ThreadID != nil {
+		builder.WriteString(" AND thread_id = ?")
+		args = append(args, *f.ThreadID)
+	}
+	if f.Source != nil {
+		builder.WriteString(" AND source = ?")
+		args = append(args, *f.Source)
+	}
+	if f.Tag != nil {
+		builder.WriteString(" AND EXISTS (SELECT 1 FROM json_each(context_items.tags) WHERE json_each.value = ?)")
+		args = append(args, *f.Tag)
+	}
+	if f.Since != 0 {
+		builder.WriteString(" AND created_at >= ?")
+		args = append(args, f.Since)
+	}
+	if f.Until != 0 {
+		builder.WriteString(" AND created_at < ?")
+		args = append(args, f.Until)
+	}
+	if f.Unwatched {
+		builder.WriteString(" AND id NOT IN (SELECT item_id FROM source_file_items)")
+	}
+
+	return builder.String(), args
+}
+
+func (d *DB) DeleteContext(ctx context.Context, id string) error {
+	res, err := d.conn.ExecContext(ctx, `DELETE FROM context_items WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete context: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete context: %w", err)
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// DeleteContexts deletes every item matching filter; Limit and Offset are
+// ignored. It returns the number of deleted items.
+func (d *DB) DeleteContexts(ctx context.Context, filter ListFilter) (int, error) {
+	where, args := filter.where()
+	res, err := d.conn.ExecContext(ctx, `DELETE FROM context_items`+where, args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete contexts: %w", err)
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("delete contexts: %w", err)
+	}
+	return int(affected), nil
+}
+
+func (d *DB) ListThreads(ctx context.Context) ([]ThreadSummary, error) {
+	rows, err := d.conn.QueryContext(
+		ctx,
+		`SELECT thread_id, COUNT(*), MIN(created_at), MAX(created_at)
+		 FROM context_items
+		 WHERE thread_id IS NOT NULL
+		 GROUP BY thread_id
+		 ORDER BY MAX(created_at) DESC`,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list threads: %w", err)
+	}
+	defer rows.Close()
+
+	threads := make([]ThreadSummary, 0)
+	for rows.Next() {
+		var thread ThreadSummary
+		if err := rows.Scan(&thread.ThreadID, &thread.Items, &thread.FirstAt, &thread.LastAt); err != nil {
+			return nil, fmt.Errorf("scan thread: %w", err)
+		}
+		threads = append(threads, thread)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate threads: %w", err)
+	}
+
+	return threads, nil
+}
+
+func (d *DB) SearchContext(ctx context.Context, query string, filter SearchFilter) ([]SearchResult, error) {
+	topK := filter.TopK
 	if topK <= 0 {
 		topK = 5
 	}
 
 	builder := strings.Builder{}
-	builder.WriteString(`SELECT ci.id, ci.title, ci.source, ci.thread_id, ci.created_at, ci.importance,
-		snippet(context_items_fts, 0, '', '', '...', 10) AS snippet
+	builder.WriteString(`SELECT ci.id, ci.title, ci.namespace, ci.source, ci.thread_id, ci.created_at, ci.importance,
+		COALESCE(snippet(context_items_fts, 0, '', '', '...', 10), substr(ci.content, 1, 160)) AS snippet
 		FROM context_items_fts
 		JOIN context_items ci ON ci.rowid = context_items_fts.rowid
 		WHERE context_items_fts MATCH ? AND ci.importance >= ?`)
 
-	args := []any{query, minImportance}
-	if threadID != nil {
+	args := []any{query, filter.MinImportance}
+	if filter.Namespace != nil {
+		builder.WriteString(" AND ci.namespace = ?")
+		args = append(args, *filter.Namespace)
+	}
+	if filter.ThreadID != nil {
 		builder.WriteString(" AND ci.thread_id = ?")
-		args = append(args, *threadID)
+		args = append(args, *filter.ThreadID)
 	}
 
 	builder.WriteString(" ORDER BY bm25(context_items_fts) LIMIT ?")
@@ -158,6 +394,9 @@ func (d *DB) SearchContext(ctx context.Context, query string, topK int, threadID
 
 	rows, err := d.conn.QueryContext(ctx, builder.String(), args...)
 	if err != nil {
+		if isQuerySyntaxError(err) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidQuery, err)
+		}
 		return nil, fmt.Errorf("search context: %w", err)
 	}
 	defer rows.Close()
@@ -166,12 +405,14 @@ func (d *DB) SearchContext(ctx context.Context, query string, topK int, threadID
 	for rows.Next() {
 		var result SearchResult
 		var title sql.NullString
+		var namespace sql.NullString
 		var source sql.NullString
 		var thread sql.NullString
 
 		if err := rows.Scan(
 			&result.ID,
 			&title,
+			&namespace,
 			&source,
 			&thread,
 			&result.CreatedAt,
@@ -182,6 +423,7 @@ func (d *DB) SearchContext(ctx context.Context, query string, topK int, threadID
 		}
 
 		result.Title = nullStringPtr(title)
+		result.Namespace = nullStringPtr(namespace)
 		result.Source = nullStringPtr(source)
 		result.ThreadID = nullStringPtr(thread)
 		results = append(results, result)
@@ -194,6 +436,182 @@ func (d *DB) SearchContext(ctx context.Context, query string, topK int, threadID
 	return results, nil
 }
 
+const contextItemColumns = `id, created_at, namespace, source, thread_id, role, title, content, tags, importance`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanContextItem(row rowScanner) (*ContextItem, error) {
+	var item ContextItem
+	var namespace sql.NullString
+	var source sql.NullString
+	var threadID sql.NullString
+	var role sql.NullString
+	var title sql.NullString
+	var tags sql.NullString
+
+	if err := row.Scan(
+		&item.ID,
+		&item.CreatedAt,
+		&namespace,
+		&source,
+		&threadID,
+		&role,
+		&title,
+		&item.Content,
+		&tags,
+		&item.Importance,
+	); err != nil {
+		return nil, err
+	}
+
+	item.Namespace = nullStringPtr(namespace)
+	item.Source = nullStringPtr(source)
+	item.ThreadID = nullStringPtr(threadID)
+	item.Role = nullStringPtr(role)
+	item.Title = nullStringPtr(title)
+
+	parsedTags, err := decodeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+	item.Tags = parsedTags
+
+	return &item, nil
+}
+
+const reindexBatchSize = 500
+
+type ftsRow struct {
+	rowid    int64
+	content  string
+	title    sql.NullString
+	tags     sql.NullString
+	threadID sql.NullString
+}
+
+// Reindex rebuilds the contentless FTS index from context_items. The index
+// cannot be rebuilt by FTS5 itself because it does not store the content.
+func (d *DB) Reindex(ctx context.Context, progress func(done int, total int)) (int, error) {
+	tx, err := d.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin reindex: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var total int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM context_items`).Scan(&total); err != nil {
+		return 0, fmt.Errorf("count context items: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO context_items_fts(context_items_fts) VALUES('delete-all')`); err != nil {
+		return 0, fmt.Errorf("clear fts index: %w", err)
+	}
+
+	done := 0
+	var lastRowID int64
+	for {
+		batch, err := readFTSBatch(ctx, tx, lastRowID)
+		if err != nil {
+			return 0, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		for _, row := range batch {
+			if _, err := tx.ExecContext(
+				ctx,
+				`INSERT INTO context_items_fts(rowid, content, title, tags, thread_id) VALUES (?, ?, ?, ?, ?)`,
+				row.rowid,
+				row.content,
+				row.title,
+				row.tags,
+				row.threadID,
+			); err != nil {
+				return 0, fmt.Errorf("index context item: %w", err)
+			}
+		}
+
+		done += len(batch)
+		lastRowID = batch[len(batch)-1].rowid
+		if progress != nil {
+			progress(done, total)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO context_items_fts(context_items_fts) VALUES('optimize')`); err != nil {
+		return 0, fmt.Errorf("optimize fts index: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit reindex: %w", err)
+	}
+
+	return done, nil
+}
+
+func readFTSBatch(ctx context.Context, tx *sql.Tx, afterRowID int64) ([]ftsRow, error) {
+	rows, err := tx.QueryContext(
+		ctx,
+		`SELECT rowid, content, title, tags, thread_id FROM context_items
+		 WHERE rowid > ? ORDER BY rowid LIMIT ?`,
+		afterRowID,
+		reindexBatchSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("read context items: %w", err)
+	}
+	defer rows.Close()
+
+	batch := make([]ftsRow, 0, reindexBatchSize)
+	for rows.Next() {
+		var row ftsRow
+		if err := rows.Scan(&row.rowid, &row.content, &row.title, &row.tags, &row.threadID); err != nil {
+			return nil, fmt.Errorf("scan context item: %w", err)
+		}
+		batch = append(batch, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate context items: %w", err)
+	}
+
+	return batch, nil
+}
+
+func isQuerySyntaxError(err error) bool {
+	message := err.Error()
+	return strings.Contains(message, "fts5: syntax error") ||
+		strings.Contains(message, "no such column") ||
+		strings.Contains(message, "unterminated string")
+}
+
+// MatchAnyWord turns free text such as a question into an FTS5 query for
+// items containing any of its words, ranked by how many match. Each word is
+// quoted, so punctuation and FTS5 operators in the text are not syntax.
+// The result is empty when the text has no words.
+func MatchAnyWord(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for i, word := range words {
+		words[i] = `"` + word + `"`
+	}
+	return strings.Join(words, " OR ")
+}
+
+// ContentHash identifies content for deduplication; surrounding whitespace
+// and line endings do not count.
+func ContentHash(content string) string {
+	normalized := strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
 func encodeTags(tags *[]string) (*string, error) {
 	if tags == nil {
 		return nil, nil
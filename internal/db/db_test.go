@@ -0,0 +1,37 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchAnyWord(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(filepath.Join(t.TempDir(), "vcontext.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	if err := store.InsertContext(ctx, ContextItem{ID: "auth", CreatedAt: 1, Content: "We rotate auth tokens weekly.", Importance: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	question := `What did we decide about "auth-tokens" (NOT refresh)?`
+	if _, err := store.SearchContext(ctx, question, SearchFilter{MinImportance: 1}); !errors.Is(err, ErrInvalidQuery) {
+		t.Fatalf("raw question: %v, want %v", err, ErrInvalidQuery)
+	}
+	results, err := store.SearchContext(ctx, MatchAnyWord(question), SearchFilter{MinImportance: 1})
+	if err != nil {
+		t.Fatalf("search %q: %v", MatchAnyWord(question), err)
+	}
+	if len(results) != 1 || results[0].ID != "auth" {
+		t.Fatalf("search = %+v, want the auth item", results)
+	}
+
+	if got := MatchAnyWord(" ?! "); got != "" {
+		t.Fatalf("MatchAnyWord without words = %q", got)
+	}
+}
@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"fmt"
+)
+
+type Health struct {
+	SchemaVersion int `json:"schema_version"`
+	// Integrity holds the result of PRAGMA integrity_check; "ok" when sound.
+	Integrity []string `json:"integrity"`
+	Items     int      `json:"items"`
+	Indexed   int      `json:"indexed"`
+	// Missing counts items absent from the FTS index, Orphaned index
+	// entries without an item.
+	Missing  int    `json:"missing_from_index"`
+	Orphaned int    `json:"orphaned_in_index"`
+	FTSError string `json:"fts_error,omitempty"`
+}
+
+func (h *Health) FTSConsistent() bool {
+	return h.FTSError == "" && h.Missing == 0 && h.Orphaned == 0
+}
+
+// Check inspects the database file and the consistency of the FTS index.
+// The index is contentless, so FTS5's own integrity-check cannot compare it
+// with context_items; row ids are compared through its docsize table.
+func (d *DB) Check(ctx context.Context) (*Health, error) {
+	health := &Health{}
+
+	version, err := d.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	health.SchemaVersion = version
+
+	rows, err := d.conn.QueryContext(ctx, `PRAGMA integrity_check`)
+	if err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("integrity check: %w", err)
+		}
+		health.Integrity = append(health.Integrity, line)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("integrity check: %w", err)
+	}
+
+	counts := []struct {
+		dest  *int
+		query string
+	}{
+		{&health.Items, `SELECT COUNT(*) FROM context_items`},
+		{&health.Indexed, `SELECT COUNT(*) FROM context_items_fts_docsize`},
+		{&health.Missing, `SELECT COUNT(*) FROM context_items WHERE rowid NOT IN (SELECT id FROM context_items_fts_docsize)`},
+		{&health.Orphaned, `SELECT COUNT(*) FROM context_items_fts_docsize WHERE id NOT IN (SELECT rowid FROM context_items)`},
+	}
+	for _, count := range counts {
+		if err := d.conn.QueryRowContext(ctx, count.query).Scan(count.dest); err != nil {
+			return nil, fmt.Errorf("count fts rows: %w", err)
+		}
+	}
+
+	if _, err := d.conn.ExecContext(ctx, `INSERT INTO context_items_fts(context_items_fts, rank) VALUES('integrity-check', 0)`); err != nil {
+		health.FTSError = err.Error()
+	}
+
+	return health, nil
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+)
+
+type migration struct {
+	sql string
+	// backfill runs after sql in the same transaction, for data that cannot
+	// be computed in SQL.
+	backfill func(ctx context.Context, tx *sql.Tx) error
+}
+
+// migrations upgrade the base schema in schema.sql. Entry i moves a database
+// from user_version i to i+1; append new entries, never edit existing ones.
+var migrations = []migration{
+	{sql: `ALTER TABLE context_items ADD COLUMN namespace TEXT;
+	 CREATE INDEX IF NOT EXISTS context_items_namespace ON context_items(namespace, created_at);`},
+	{
+		sql: `ALTER TABLE context_items ADD COLUMN content_hash TEXT;
+		 CREATE INDEX IF NOT EXISTS context_items_content_hash ON context_items(content_hash);
+		 DROP TRIGGER IF EXISTS context_items_au;
+		 CREATE TRIGGER context_items_au AFTER UPDATE OF content, title, tags, thread_id ON context_items BEGIN
+		   INSERT INTO context_items_fts(context_items_fts, rowid, content, title, tags, thread_id)
+		     VALUES('delete', old.rowid, old.content, old.title, old.tags, old.thread_id);
+		   INSERT INTO context_items_fts(rowid, content, title, tags, thread_id)
+		     VALUES (new.rowid, new.content, new.title, new.tags, new.thread_id);
+		 END;`,
+		backfill: backfillContentHashes,
+	},
+	{sql: `CREATE TABLE IF NOT EXISTS source_files (
+		   path TEXT PRIMARY KEY,
+		   source TEXT NOT NULL,
+		   mtime INTEGER NOT NULL,
+		   size INTEGER NOT NULL,
+		   hash TEXT NOT NULL,
+		   indexed_at INTEGER NOT NULL
+		 );
+		 CREATE INDEX IF NOT EXISTS context_items_source ON context_items(source);`},
+	{sql: `CREATE TABLE IF NOT EXISTS source_file_items (
+		   path TEXT NOT NULL,
+		   item_id TEXT NOT NULL,
+		   PRIMARY KEY (path, item_id)
+		 );
+		 CREATE INDEX IF NOT EXISTS source_file_items_item ON source_file_items(item_id);
+		 INSERT OR IGNORE INTO source_file_items (path, item_id)
+		   SELECT sf.path, ci.id FROM source_files sf JOIN context_items ci ON ci.source = sf.source;`},
+}
+
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+func (d *DB) SchemaVersion(ctx context.Context) (int, error) {
+	return userVersion(ctx, d.conn)
+}
+
+func userVersion(ctx context.Context, conn *sql.DB) (int, error) {
+	var version int
+	if err := conn.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	return version, nil
+}
+
+func migrate(ctx context.Context, conn *sql.DB, logger *slog.Logger) error {
+	current, err := userVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("schema version %d is newer than this binary supports (%d)", current, len(migrations))
+	}
+
+	for version := current; version < len(migrations); version++ {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration %d: %w", version+1, err)
+		}
+		step := migrations[version]
+		if _, err := tx.ExecContext(ctx, step.sql); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("apply migration %d: %w", version+1, err)
+		}
+		if step.backfill != nil {
+			if err := step.backfill(ctx, tx); err != nil {
+				_ = tx.Rollback()
+				return fmt.Errorf("apply migration %d: %w", version+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, version+1)); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("record migration %d: %w", version+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", version+1, err)
+		}
+		logger.Info("migrated database schema", "version", version+1)
+	}
+
+	return nil
+}
+
+func backfillContentHashes(ctx context.Context, tx *sql.Tx) error {
+	var lastRowID int64
+	for {
+		batch, err := readFTSBatch(ctx, tx, lastRowID)
+		if err != nil {
+			return err
+		}
+		if len(batch) == 0 {
+			return nil
+		}
+		for _, row := range batch {
+			if _, err := tx.ExecContext(ctx, `UPDATE context_items SET content_hash = ? WHERE rowid = ?`, ContentHash(row.content), row.rowid); err != nil {
+				return fmt.Errorf("store content hash: %w", err)
+			}
+		}
+		lastRowID = batch[len(batch)-1].rowid
+	}
+}
@@ -3,6 +3,7 @@ package db
 type ContextItem struct {
 	ID         string    `json:"id"`
 	CreatedAt  int64     `json:"created_at"`
+	Namespace  *string   `json:"namespace,omitempty"`
 	Source     *string   `json:"source,omitempty"`
 	ThreadID   *string   `json:"thread_id,omitempty"`
 	Role       *string   `json:"role,omitempty"`
@@ -15,9 +16,39 @@ type ContextItem struct {
 type SearchResult struct {
 	ID         string  `json:"id"`
 	Title      *string `json:"title,omitempty"`
+	Namespace  *string `json:"namespace,omitempty"`
 	Source     *string `json:"source,omitempty"`
 	ThreadID   *string `json:"thread_id,omitempty"`
 	CreatedAt  int64   `json:"created_at"`
 	Importance int     `json:"importance"`
 	Snippet    string  `json:"snippet"`
 }
+
+type ListFilter struct {
+	Namespace *string
+	ThreadID  *string
+	Source    *string
+	Tag       *string
+	// Since and Until bound created_at in unix seconds; zero leaves the bound open.
+	Since int64
+	Until int64
+	// Unwatched leaves out items mirrored from watched files; they live as
+	// long as their file does.
+	Unwatched bool
+	Limit     int
+	Offset    int
+}
+
+type SearchFilter struct {
+	TopK          int
+	Namespace     *string
+	ThreadID      *string
+	MinImportance int
+}
+
+type ThreadSummary struct {
+	ThreadID string `json:"thread_id"`
+	Items    int    `json:"items"`
+	FirstAt  int64  `json:"first_at"`
+	LastAt   int64  `json:"last_at"`
+}
@@ -0,0 +1,128 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// SourceFile records a file mirrored into memory. Its items carry Source,
+// but the file owns only the items it created, which are tracked by id.
+type SourceFile struct {
+	Path    string
+	Source  string
+	ModTime int64
+	Size    int64
+	Hash    string
+}
+
+// SourceFiles lists tracked files whose path starts with prefix.
+func (d *DB) SourceFiles(ctx context.Context, prefix string) ([]SourceFile, error) {
+	rows, err := d.conn.QueryContext(
+		ctx,
+		`SELECT path, source, mtime, size, hash FROM source_files
+		 WHERE instr(path, ?) = 1 ORDER BY path`,
+		prefix,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list source files: %w", err)
+	}
+	defer rows.Close()
+
+	files := make([]SourceFile, 0)
+	for rows.Next() {
+		var file SourceFile
+		if err := rows.Scan(&file.Path, &file.Source, &file.ModTime, &file.Size, &file.Hash); err != nil {
+			return nil, fmt.Errorf("scan source file: %w", err)
+		}
+		files = append(files, file)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate source files: %w", err)
+	}
+
+	return files, nil
+}
+
+// ReplaceSourceFile swaps the items of file for items in one transaction.
+func (d *DB) ReplaceSourceFile(ctx context.Context, file SourceFile, items []ContextItem) error {
+	return d.inTx(ctx, "replace source file", func(tx *sql.Tx) error {
+		if err := deleteSourceItems(ctx, tx, file.Path); err != nil {
+			return err
+		}
+		if err := insertContexts(ctx, tx, items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			if _, err := tx.ExecContext(ctx, `INSERT INTO source_file_items (path, item_id) VALUES (?, ?)`, file.Path, item.ID); err != nil {
+				return err
+			}
+		}
+		return saveSourceFile(ctx, tx, file)
+	})
+}
+
+// TouchSourceFile records a new mtime for a file whose content is unchanged.
+func (d *DB) TouchSourceFile(ctx context.Context, file SourceFile) error {
+	return d.inTx(ctx, "touch source file", func(tx *sql.Tx) error {
+		return saveSourceFile(ctx, tx, file)
+	})
+}
+
+// DeleteSourceFile forgets file and removes its items.
+func (d *DB) DeleteSourceFile(ctx context.Context, file SourceFile) error {
+	return d.inTx(ctx, "delete source file", func(tx *sql.Tx) error {
+		if err := deleteSourceItems(ctx, tx, file.Path); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM source_files WHERE path = ?`, file.Path)
+		return err
+	})
+}
+
+// deleteSourceItems removes the items the file at path created, leaving
+// items saved or imported with the same source alone.
+func deleteSourceItems(ctx context.Context, tx *sql.Tx, path string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM context_items WHERE id IN (SELECT item_id FROM source_file_items WHERE path = ?)`, path); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `DELETE FROM source_file_items WHERE path = ?`, path)
+	return err
+}
+
+func saveSourceFile(ctx context.Context, tx *sql.Tx, file SourceFile) error {
+	_, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO source_files (path, source, mtime, size, hash, indexed_at) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(path) DO UPDATE SET source = excluded.source, mtime = excluded.mtime,
+		   size = excluded.size, hash = excluded.hash, indexed_at = excluded.indexed_at`,
+		file.Path,
+		file.Source,
+		file.ModTime,
+		file.Size,
+		file.Hash,
+		time.Now().Unix(),
+	)
+	return err
+}
+
+func (d *DB) inTx(ctx context.Context, action string, fn func(tx *sql.Tx) error) error {
+	tx, err := d.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin %s: %w", action, err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := fn(tx); err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit %s: %w", action, err)
+	}
+	return nil
+}
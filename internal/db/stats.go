@@ -0,0 +1,246 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+type Stats struct {
+	Items        int   `json:"items"`
+	ContentBytes int64 `json:"content_bytes"`
+	FirstAt      int64 `json:"first_at,omitempty"`
+	LastAt       int64 `json:"last_at,omitempty"`
+
+	Namespaces []ValueCount      `json:"namespaces"`
+	Threads    []ValueCount      `json:"threads"`
+	Sources    []ValueCount      `json:"sources"`
+	Roles      []ValueCount      `json:"roles"`
+	Tags       []ValueCount      `json:"tags"`
+	Importance []ImportanceCount `json:"importance"`
+	PerDay     []DayCount        `json:"per_day"`
+	Largest    []ItemSize        `json:"largest"`
+
+	// DatabaseBytes and IndexBytes describe the whole file and are not
+	// narrowed by the namespace filter.
+	DatabaseBytes int64 `json:"database_bytes"`
+	IndexBytes    int64 `json:"index_bytes"`
+}
+
+// ValueCount is one row of a breakdown; a nil Value groups the items
+// that leave the field unset.
+type ValueCount struct {
+	Value *string `json:"value"`
+	Items int     `json:"items"`
+	Bytes int64   `json:"bytes"`
+}
+
+type ImportanceCount struct {
+	Importance int `json:"importance"`
+	Items      int `json:"items"`
+}
+
+type DayCount struct {
+	Day   string `json:"day"`
+	Items int    `json:"items"`
+}
+
+type ItemSize struct {
+	ID        string  `json:"id"`
+	Title     *string `json:"title,omitempty"`
+	Namespace *string `json:"namespace,omitempty"`
+	ThreadID  *string `json:"thread_id,omitempty"`
+	CreatedAt int64   `json:"created_at"`
+	Bytes     int64   `json:"bytes"`
+}
+
+type StatsFilter struct {
+	Namespace *string
+	// Top bounds each breakdown and the list of largest items.
+	Top int
+	// Since starts the per-day histogram, in unix seconds.
+	Since int64
+}
+
+const contentBytes = `LENGTH(CAST(content AS BLOB))`
+
+// Stats aggregates the stored items. Every figure comes from a GROUP BY
+// query, so the cost does not depend on the size of the content.
+func (d *DB) Stats(ctx context.Context, filter StatsFilter) (*Stats, error) {
+	top := filter.Top
+	if top <= 0 {
+		top = 10
+	}
+
+	tx, err := d.conn.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("stats: %w", err)
+	}
+	defer tx.Rollback()
+
+	where, args := ListFilter{Namespace: filter.Namespace}.where()
+	stats := &Stats{}
+
+	var firstAt, lastAt sql.NullInt64
+	err = tx.QueryRowContext(ctx,
+		`SELECT COUNT(*), COALESCE(SUM(`+contentBytes+`), 0), MIN(created_at), MAX(created_at)
+		 FROM context_items`+where, args...,
+	).Scan(&stats.Items, &stats.ContentBytes, &firstAt, &lastAt)
+	if err != nil {
+		return nil, fmt.Errorf("stats: %w", err)
+	}
+	stats.FirstAt = firstAt.Int64
+	stats.LastAt = lastAt.Int64
+
+	breakdowns := []struct {
+		dest  *[]ValueCount
+		value string
+		from  string
+	}{
+		{&stats.Namespaces, "namespace", "context_items"},
+		{&stats.Threads, "thread_id", "context_items"},
+		{&stats.Sources, "source", "context_items"},
+		{&stats.Roles, "role", "context_items"},
+		{&stats.Tags, "tag.value", "context_items, json_each(context_items.tags) AS tag"},
+	}
+	for _, breakdown := range breakdowns {
+		counts, err := valueCounts(ctx, tx, breakdown.value, breakdown.from+where, args, top)
+		if err != nil {
+			return nil, err
+		}
+		*breakdown.dest = counts
+	}
+
+	stats.Importance, err = importanceCounts(ctx, tx, where, args)
+	if err != nil {
+		return nil, err
+	}
+	stats.PerDay, err = dayCounts(ctx, tx, where, args, filter.Since)
+	if err != nil {
+		return nil, err
+	}
+	stats.Largest, err = largestItems(ctx, tx, where, args, top)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRowContext(ctx,
+		`SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()`,
+	).Scan(&stats.DatabaseBytes)
+	if err != nil {
+		return nil, fmt.Errorf("stats: database size: %w", err)
+	}
+	err = tx.QueryRowContext(ctx,
+		`SELECT COALESCE(SUM(pgsize), 0) FROM dbstat WHERE name LIKE 'context_items_fts%'`,
+	).Scan(&stats.IndexBytes)
+	if err != nil {
+		return nil, fmt.Errorf("stats: index size: %w", err)
+	}
+
+	return stats, nil
+}
+
+func valueCounts(ctx context.Context, tx *sql.Tx, value string, from string, args []any, top int) ([]ValueCount, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT `+value+`, COUNT(*), SUM(`+contentBytes+`) FROM `+from+
+			` GROUP BY 1 ORDER BY 2 DESC, 1 LIMIT ?`,
+		append(args, top)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("stats: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make([]ValueCount, 0)
+	for rows.Next() {
+		var count ValueCount
+		var value sql.NullString
+		if err := rows.Scan(&value, &count.Items, &count.Bytes); err != nil {
+			return nil, fmt.Errorf("stats: %w", err)
+		}
+		count.Value = nullStringPtr(value)
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("stats: %w", err)
+	}
+	return counts, nil
+}
+
+func importanceCounts(ctx context.Context, tx *sql.Tx, where string, args []any) ([]ImportanceCount, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT importance, COUNT(*) FROM context_items`+where+` GROUP BY importance ORDER BY importance`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("stats: importance: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make([]ImportanceCount, 0, 5)
+	for rows.Next() {
+		var count ImportanceCount
+		if err := rows.Scan(&count.Importance, &count.Items); err != nil {
+			return nil, fmt.Errorf("stats: importance: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("stats: importance: %w", err)
+	}
+	return counts, nil
+}
+
+func dayCounts(ctx context.Context, tx *sql.Tx, where string, args []any, since int64) ([]DayCount, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT date(created_at, 'unixepoch') AS day, COUNT(*) FROM context_items`+where+
+			` AND created_at >= ? GROUP BY day ORDER BY day`,
+		append(args, since)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("stats: per day: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make([]DayCount, 0)
+	for rows.Next() {
+		var count DayCount
+		if err := rows.Scan(&count.Day, &count.Items); err != nil {
+			return nil, fmt.Errorf("stats: per day: %w", err)
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("stats: per day: %w", err)
+	}
+	return counts, nil
+}
+
+func largestItems(ctx context.Context, tx *sql.Tx, where string, args []any, top int) ([]ItemSize, error) {
+	rows, err := tx.QueryContext(ctx,
+		`SELECT id, title, namespace, thread_id, created_at, `+contentBytes+` AS size
+		 FROM context_items`+where+` ORDER BY size DESC, created_at DESC LIMIT ?`,
+		append(args, top)...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("stats: largest items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]ItemSize, 0, top)
+	for rows.Next() {
+		var item ItemSize
+		var title, namespace, threadID sql.NullString
+		if err := rows.Scan(&item.ID, &title, &namespace, &threadID, &item.CreatedAt, &item.Bytes); err != nil {
+			return nil, fmt.Errorf("stats: largest items: %w", err)
+		}
+		item.Title = nullStringPtr(title)
+		item.Namespace = nullStringPtr(namespace)
+		item.ThreadID = nullStringPtr(threadID)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("stats: largest items: %w", err)
+	}
+	return items, nil
+}
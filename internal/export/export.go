@@ -0,0 +1,258 @@
+package export
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"vcontext/internal/db"
+)
+
+type Format string
+
+const (
+	FormatJSONL    Format = "jsonl"
+	FormatMarkdown Format = "markdown"
+	FormatCSV      Format = "csv"
+)
+
+// Writer receives items one at a time so exports never hold the whole
+// database in memory.
+type Writer interface {
+	Write(item db.ContextItem) error
+	Close() error
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func NewJSONLWriter(w io.Writer) Writer {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return &jsonlWriter{encoder: encoder}
+}
+
+func (w *jsonlWriter) Write(item db.ContextItem) error {
+	return w.encoder.Encode(item)
+}
+
+func (w *jsonlWriter) Close() error {
+	return nil
+}
+
+var csvHeader = []string{"id", "created_at", "namespace", "thread_id", "source", "role", "title", "tags", "importance", "content"}
+
+type csvWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func NewCSVWriter(w io.Writer) Writer {
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(item db.ContextItem) error {
+	if !w.header {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+		w.header = true
+	}
+
+	tags := ""
+	if item.Tags != nil {
+		tags = strings.Join(*item.Tags, ",")
+	}
+	return w.writer.Write([]string{
+		item.ID,
+		formatTime(item.CreatedAt),
+		deref(item.Namespace),
+		deref(item.ThreadID),
+		deref(item.Source),
+		deref(item.Role),
+		deref(item.Title),
+		tags,
+		strconv.Itoa(item.Importance),
+		item.Content,
+	})
+}
+
+func (w *csvWriter) Close() error {
+	if !w.header {
+		if err := w.writer.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// UnthreadedFile holds items without a thread in a Markdown export.
+const UnthreadedFile = "_unthreaded.md"
+
+type markdownWriter struct {
+	dir     string
+	file    *os.File
+	current string
+	written map[string]bool
+	// files maps a thread to its file name, and owners maps a case-folded
+	// file name back to the thread, so threads whose names only differ in
+	// case or punctuation never share a file.
+	files  map[string]string
+	owners map[string]string
+}
+
+// unthreadedKey stands for items without a thread in markdownWriter.
+const unthreadedKey = "\x00"
+
+// NewMarkdownWriter writes one file per thread into dir. Every item becomes a
+// front-matter block followed by its content. Items of a thread should arrive
+// together; a thread seen again is appended to its file.
+func NewMarkdownWriter(dir string) (Writer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create export dir: %w", err)
+	}
+	return &markdownWriter{
+		dir:     dir,
+		written: map[string]bool{},
+		files:   map[string]string{unthreadedKey: UnthreadedFile},
+		owners:  map[string]string{strings.ToLower(UnthreadedFile): unthreadedKey},
+	}, nil
+}
+
+// fileFor picks the file of a thread. A name already taken by another
+// thread, ignoring case as macOS and Windows do, gets a suffix derived from
+// the thread id.
+func (w *markdownWriter) fileFor(threadID string) string {
+	if name, ok := w.files[threadID]; ok {
+		return name
+	}
+	name := ThreadFileName(threadID)
+	if _, taken := w.owners[strings.ToLower(name)]; taken {
+		sum := sha256.Sum256([]byte(threadID))
+		base := strings.TrimSuffix(name, ".md") + "-" + hex.EncodeToString(sum[:4])
+		name = base + ".md"
+		for i := 2; ; i++ {
+			if _, taken := w.owners[strings.ToLower(name)]; !taken {
+				break
+			}
+			name = fmt.Sprintf("%s-%d.md", base, i)
+		}
+	}
+	w.files[threadID] = name
+	w.owners[strings.ToLower(name)] = threadID
+	return name
+}
+
+func (w *markdownWriter) Write(item db.ContextItem) error {
+	name := UnthreadedFile
+	if item.ThreadID != nil && *item.ThreadID != "" {
+		name = w.fileFor(*item.ThreadID)
+	}
+
+	if w.file == nil || name != w.current {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if w.written[name] {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		file, err := os.OpenFile(filepath.Join(w.dir, name), flags, 0o644)
+		if err != nil {
+			return fmt.Errorf("open export file: %w", err)
+		}
+		w.file = file
+		w.current = name
+	}
+
+	var b strings.Builder
+	if w.written[name] {
+		b.WriteString("\n")
+	}
+	w.written[name] = true
+
+	b.WriteString("---\n")
+	writeField(&b, "id", item.ID)
+	writeField(&b, "created_at", formatTime(item.CreatedAt))
+	writeOptional(&b, "namespace", item.Namespace)
+	writeOptional(&b, "thread_id", item.ThreadID)
+	writeOptional(&b, "source", item.Source)
+	writeOptional(&b, "role", item.Role)
+	writeOptional(&b, "title", item.Title)
+	if item.Tags != nil && len(*item.Tags) > 0 {
+		tags, _ := json.Marshal(*item.Tags)
+		fmt.Fprintf(&b, "tags: %s\n", tags)
+	}
+	fmt.Fprintf(&b, "importance: %d\n", item.Importance)
+	b.WriteString("---\n\n")
+	b.WriteString(strings.TrimRight(item.Content, "\n"))
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w.file, b.String())
+	return err
+}
+
+func (w *markdownWriter) Close() error {
+	return w.closeFile()
+}
+
+func (w *markdownWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// ThreadFileName maps a thread id to a portable file name.
+func ThreadFileName(threadID string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, threadID)
+	name = strings.Trim(name, ".")
+	if name == "" {
+		name = "thread"
+	}
+	return name + ".md"
+}
+
+// writeField quotes values as JSON strings, which front-matter (YAML) readers
+// accept as double-quoted scalars.
+func writeField(b *strings.Builder, key string, value string) {
+	quoted, _ := json.Marshal(value)
+	fmt.Fprintf(b, "%s: %s\n", key, quoted)
+}
+
+func writeOptional(b *strings.Builder, key string, value *string) {
+	if value == nil {
+		return
+	}
+	writeField(b, key, *value)
+}
+
+func formatTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
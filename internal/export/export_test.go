@@ -0,0 +1,63 @@
+package export
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"vcontext/internal/db"
+)
+
+// Threads whose file names differ only in case or punctuation get files of
+// their own, distinct even on case-insensitive file systems.
+func TestMarkdownThreadFilesDoNotCollide(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewMarkdownWriter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	threads := []string{"Foo", "foo", "a/b", "a_b", "_unthreaded", ""}
+	for i, thread := range threads {
+		item := db.ContextItem{ID: "item-" + thread, CreatedAt: int64(i), Content: "content of " + thread, Importance: 3}
+		if thread != "" {
+			item.ThreadID = &thread
+		}
+		if err := w.Write(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	folded := map[string]bool{}
+	for _, entry := range entries {
+		folded[strings.ToLower(entry.Name())] = true
+	}
+	if len(entries) != len(threads) || len(folded) != len(threads) {
+		t.Fatalf("wrote %d files (%d ignoring case), want %d", len(entries), len(folded), len(threads))
+	}
+
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(data), "content of "); got != 1 {
+			t.Fatalf("%s holds %d items, want 1:\n%s", entry.Name(), got, data)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, UnthreadedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `id: "item-"`) {
+		t.Fatalf("%s does not hold the unthreaded item:\n%s", UnthreadedFile, data)
+	}
+}
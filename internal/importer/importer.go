@@ -0,0 +1,376 @@
+package importer
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"vcontext/internal/db"
+)
+
+type Type string
+
+const (
+	TypeAuto     Type = "auto"
+	TypeJSONL    Type = "jsonl"
+	TypeMarkdown Type = "markdown"
+	TypeText     Type = "text"
+)
+
+const (
+	defaultBatchSize  = 500
+	defaultImportance = 3
+	maxLineBytes      = 16 << 20
+)
+
+func ParseType(value string) (Type, error) {
+	switch Type(strings.ToLower(strings.TrimSpace(value))) {
+	case "", TypeAuto:
+		return TypeAuto, nil
+	case TypeJSONL, "json", "ndjson":
+		return TypeJSONL, nil
+	case TypeMarkdown, "md":
+		return TypeMarkdown, nil
+	case TypeText, "txt":
+		return TypeText, nil
+	default:
+		return "", fmt.Errorf("unknown import type %q (want auto, jsonl, markdown or text)", value)
+	}
+}
+
+// DetectType maps a file extension to an import type, or "" when the file is
+// not importable.
+func DetectType(path string) Type {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return TypeJSONL
+	case ".md", ".markdown":
+		return TypeMarkdown
+	case ".txt", ".text":
+		return TypeText
+	default:
+		return ""
+	}
+}
+
+type Options struct {
+	Type Type
+	// Namespace is applied to items that do not carry their own.
+	Namespace *string
+	DryRun    bool
+	BatchSize int
+}
+
+type Failure struct {
+	Origin string `json:"origin"`
+	Error  string `json:"error"`
+}
+
+type Summary struct {
+	Created  int       `json:"created"`
+	Skipped  int       `json:"skipped"`
+	Failed   int       `json:"failed"`
+	DryRun   bool      `json:"dry_run,omitempty"`
+	Failures []Failure `json:"failures,omitempty"`
+}
+
+type record struct {
+	item   db.ContextItem
+	origin string
+}
+
+type importer struct {
+	store   *db.DB
+	opts    Options
+	batch   []record
+	seen    map[string]bool
+	summary Summary
+}
+
+// Run imports every path (files or directories walked recursively) into
+// store. Items whose id or content already exists are skipped; unreadable
+// or invalid input is reported in the summary rather than aborting the run.
+func Run(ctx context.Context, store *db.DB, paths []string, opts Options) (Summary, error) {
+	if opts.Type == "" {
+		opts.Type = TypeAuto
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+
+	im := &importer{store: store, opts: opts, seen: map[string]bool{}}
+	im.summary.DryRun = opts.DryRun
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return im.summary, err
+		}
+		if info.IsDir() {
+			err = im.importDir(ctx, path)
+		} else {
+			typ := opts.Type
+			if typ == TypeAuto {
+				typ = DetectType(path)
+			}
+			if typ == "" {
+				im.fail(path, fmt.Errorf("unknown file type; pass -type"))
+				continue
+			}
+			err = im.importFile(ctx, path, typ)
+		}
+		if err != nil {
+			return im.summary, err
+		}
+	}
+
+	if err := im.flush(ctx); err != nil {
+		return im.summary, err
+	}
+	return im.summary, nil
+}
+
+func (im *importer) importDir(ctx context.Context, dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			im.fail(path, err)
+			return nil
+		}
+		if path != dir && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		typ := DetectType(path)
+		if typ == "" || im.opts.Type != TypeAuto && typ != im.opts.Type {
+			return nil
+		}
+		return im.importFile(ctx, path, typ)
+	})
+}
+
+func (im *importer) importFile(ctx context.Context, path string, typ Type) error {
+	if typ == TypeJSONL {
+		return im.importJSONL(ctx, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		im.fail(path, err)
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		im.fail(path, err)
+		return nil
+	}
+
+	items, err := ParseFile(path, data, info.ModTime(), typ)
+	if err != nil {
+		im.fail(path, err)
+		return nil
+	}
+	for i, item := range items {
+		origin := path
+		if len(items) > 1 {
+			origin = fmt.Sprintf("%s#%d", path, i+1)
+		}
+		if err := im.add(ctx, record{item: item, origin: origin}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseFile converts the contents of a Markdown or text file into items.
+// Only items of a Markdown export carry ids. JSONL is not a per-file format
+// and is rejected.
+func ParseFile(path string, data []byte, modTime time.Time, typ Type) ([]db.ContextItem, error) {
+	switch typ {
+	case TypeMarkdown:
+		return parseMarkdown(path, string(data), modTime)
+	case TypeText:
+		if strings.TrimSpace(string(data)) == "" {
+			return nil, nil
+		}
+		return []db.ContextItem{{
+			CreatedAt: modTime.Unix(),
+			Source:    stringPtr(SourceFor(path)),
+			Title:     stringPtr(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))),
+			Content:   string(data),
+		}}, nil
+	default:
+		return nil, fmt.Errorf("cannot parse %s files", typ)
+	}
+}
+
+func (im *importer) importJSONL(ctx context.Context, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		im.fail(path, err)
+		return nil
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		origin := fmt.Sprintf("%s:%d", path, line)
+		var item db.ContextItem
+		if err := json.Unmarshal([]byte(text), &item); err != nil {
+			im.fail(origin, fmt.Errorf("invalid json: %w", err))
+			continue
+		}
+		if err := im.add(ctx, record{item: item, origin: origin}); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		im.fail(fmt.Sprintf("%s:%d", path, line+1), err)
+	}
+	return nil
+}
+
+func (im *importer) add(ctx context.Context, rec record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	item := &rec.item
+	if strings.TrimSpace(item.Content) == "" {
+		im.fail(rec.origin, fmt.Errorf("content is empty"))
+		return nil
+	}
+	if item.Namespace == nil {
+		item.Namespace = im.opts.Namespace
+	}
+	if item.Importance == 0 {
+		item.Importance = defaultImportance
+	}
+	if item.CreatedAt == 0 {
+		item.CreatedAt = time.Now().Unix()
+	}
+
+	if item.ID != "" {
+		if im.seen["id\x00"+item.ID] {
+			im.summary.Skipped++
+			return nil
+		}
+		exists, err := im.store.ContextExists(ctx, item.ID)
+		if err != nil {
+			return err
+		}
+		if exists {
+			im.summary.Skipped++
+			return nil
+		}
+	} else {
+		item.ID = uuid.NewString()
+	}
+
+	hash := db.ContentHash(item.Content)
+	contentKey := "content\x00" + deref(item.Namespace) + "\x00" + hash
+	if im.seen[contentKey] {
+		im.summary.Skipped++
+		return nil
+	}
+	exists, err := im.store.HasContent(ctx, item.Namespace, hash)
+	if err != nil {
+		return err
+	}
+	if exists {
+		im.summary.Skipped++
+		return nil
+	}
+
+	im.seen["id\x00"+item.ID] = true
+	im.seen[contentKey] = true
+	im.batch = append(im.batch, rec)
+	if len(im.batch) >= im.opts.BatchSize {
+		return im.flush(ctx)
+	}
+	return nil
+}
+
+func (im *importer) flush(ctx context.Context) error {
+	if len(im.batch) == 0 {
+		return nil
+	}
+	batch := im.batch
+	im.batch = nil
+
+	if im.opts.DryRun {
+		im.summary.Created += len(batch)
+		return nil
+	}
+
+	items := make([]db.ContextItem, 0, len(batch))
+	for _, rec := range batch {
+		items = append(items, rec.item)
+	}
+	if err := im.store.InsertContexts(ctx, items); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		for _, rec := range batch {
+			im.fail(rec.origin, err)
+		}
+		return nil
+	}
+	im.summary.Created += len(batch)
+	return nil
+}
+
+func (im *importer) fail(origin string, err error) {
+	im.summary.Failed++
+	im.summary.Failures = append(im.summary.Failures, Failure{Origin: origin, Error: err.Error()})
+}
+
+// SourceFor is the source recorded for items read from a file:
+// "file:" followed by the slash-separated path, relative to the working
+// directory when the file lies below it.
+func SourceFor(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				path = rel
+			}
+		}
+	}
+	return "file:" + filepath.ToSlash(path)
+}
+
+func stringPtr(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -0,0 +1,238 @@
+package importer
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"vcontext/internal/db"
+)
+
+var headingPattern = regexp.MustCompile(`^(#{1,2})\s+(.+?)\s*#*\s*$`)
+
+// exportSeparator starts every item after the first in a file written by
+// vcontext export -format markdown.
+const exportSeparator = "\n\n---\nid: "
+
+type frontMatter struct {
+	ID         string     `yaml:"id"`
+	Title      string     `yaml:"title"`
+	Namespace  string     `yaml:"namespace"`
+	ThreadID   string     `yaml:"thread_id"`
+	Thread     string     `yaml:"thread"`
+	Source     string     `yaml:"source"`
+	Role       string     `yaml:"role"`
+	Tags       stringList `yaml:"tags"`
+	Importance int        `yaml:"importance"`
+	CreatedAt  string     `yaml:"created_at"`
+	Date       string     `yaml:"date"`
+}
+
+// stringList accepts both a YAML sequence and a comma separated string.
+type stringList []string
+
+func (l *stringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		for _, part := range strings.Split(node.Value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				*l = append(*l, part)
+			}
+		}
+		return nil
+	}
+	var items []string
+	if err := node.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+type section struct {
+	heading string
+	lines   []string
+}
+
+// parseMarkdown turns a note into one item per level 1 or 2 heading.
+// Front matter supplies the metadata shared by all chunks. Files written by
+// vcontext export, recognised by the id in their front matter, hold one item
+// per front-matter block instead.
+func parseMarkdown(path string, text string, modTime time.Time) ([]db.ContextItem, error) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+
+	meta, text, err := cutFrontMatter(text)
+	if err != nil {
+		return nil, err
+	}
+	if meta.ID != "" {
+		return parseExported(meta, text, modTime)
+	}
+	createdAt, err := meta.createdAt(modTime)
+	if err != nil {
+		return nil, err
+	}
+
+	sections := splitSections(text)
+	title := meta.Title
+	if title == "" && len(sections) > 0 && sections[0].heading != "" && strings.HasPrefix(sections[0].lines[0], "# ") {
+		title = sections[0].heading
+	}
+	if title == "" {
+		title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+
+	source := meta.Source
+	if source == "" {
+		source = SourceFor(path)
+	}
+
+	items := make([]db.ContextItem, 0, len(sections))
+	for _, sec := range sections {
+		body := sec.lines
+		if sec.heading != "" {
+			body = body[1:]
+		}
+		if strings.TrimSpace(strings.Join(body, "\n")) == "" {
+			continue
+		}
+
+		chunkTitle := title
+		if sec.heading != "" && sec.heading != title {
+			chunkTitle = title + " / " + sec.heading
+		}
+		items = append(items, db.ContextItem{
+			CreatedAt:  createdAt,
+			Namespace:  stringPtr(meta.Namespace),
+			Source:     stringPtr(source),
+			ThreadID:   stringPtr(meta.thread()),
+			Role:       stringPtr(meta.Role),
+			Title:      stringPtr(chunkTitle),
+			Content:    strings.TrimSpace(strings.Join(sec.lines, "\n")),
+			Tags:       meta.tags(),
+			Importance: meta.Importance,
+		})
+	}
+	return items, nil
+}
+
+// parseExported reads the items of a file written by vcontext export
+// -format markdown: front-matter blocks, each followed by the content of its
+// item. meta and text are the first block and everything after it.
+func parseExported(meta frontMatter, text string, modTime time.Time) ([]db.ContextItem, error) {
+	var items []db.ContextItem
+	for {
+		content, next, more := strings.Cut(text, exportSeparator)
+		createdAt, err := meta.createdAt(modTime)
+		if err != nil {
+			return nil, fmt.Errorf("item %s: %w", meta.ID, err)
+		}
+		items = append(items, db.ContextItem{
+			ID:         meta.ID,
+			CreatedAt:  createdAt,
+			Namespace:  stringPtr(meta.Namespace),
+			Source:     stringPtr(meta.Source),
+			ThreadID:   stringPtr(meta.thread()),
+			Role:       stringPtr(meta.Role),
+			Title:      stringPtr(meta.Title),
+			Content:    strings.TrimSpace(content),
+			Tags:       meta.tags(),
+			Importance: meta.Importance,
+		})
+		if !more {
+			return items, nil
+		}
+
+		meta, text, err = cutFrontMatter("---\nid: " + next)
+		if err != nil {
+			return nil, fmt.Errorf("after item %s: %w", items[len(items)-1].ID, err)
+		}
+	}
+}
+
+// cutFrontMatter splits a leading front-matter block from the rest of text.
+func cutFrontMatter(text string) (frontMatter, string, error) {
+	var meta frontMatter
+	rest, ok := strings.CutPrefix(text, "---\n")
+	if !ok {
+		return meta, text, nil
+	}
+	end := strings.Index(rest, "\n---\n")
+	if end < 0 && strings.HasSuffix(rest, "\n---") {
+		end = len(rest) - len("\n---")
+	}
+	if end < 0 {
+		return meta, text, nil
+	}
+	if err := yaml.Unmarshal([]byte(rest[:end]), &meta); err != nil {
+		return meta, text, fmt.Errorf("front matter: %w", err)
+	}
+	return meta, strings.TrimPrefix(rest[end+len("\n---"):], "\n"), nil
+}
+
+func (m frontMatter) createdAt(modTime time.Time) (int64, error) {
+	for _, value := range []string{m.CreatedAt, m.Date} {
+		if value == "" {
+			continue
+		}
+		parsed, err := parseTime(value)
+		if err != nil {
+			return 0, fmt.Errorf("front matter: %w", err)
+		}
+		return parsed.Unix(), nil
+	}
+	return modTime.Unix(), nil
+}
+
+func (m frontMatter) thread() string {
+	if m.ThreadID != "" {
+		return m.ThreadID
+	}
+	return m.Thread
+}
+
+func (m frontMatter) tags() *[]string {
+	if len(m.Tags) == 0 {
+		return nil
+	}
+	list := []string(m.Tags)
+	return &list
+}
+
+func splitSections(text string) []section {
+	var sections []section
+	current := section{}
+	fence := ""
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence == "" && (strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")) {
+			fence = trimmed[:3]
+		} else if fence != "" && strings.HasPrefix(trimmed, fence) {
+			fence = ""
+		} else if fence == "" {
+			if match := headingPattern.FindStringSubmatch(line); match != nil {
+				if len(current.lines) > 0 {
+					sections = append(sections, current)
+				}
+				current = section{heading: match[2]}
+			}
+		}
+		current.lines = append(current.lines, line)
+	}
+	if len(current.lines) > 0 {
+		sections = append(sections, current)
+	}
+	return sections
+}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if parsed, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
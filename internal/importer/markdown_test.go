@@ -0,0 +1,79 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"vcontext/internal/db"
+	"vcontext/internal/export"
+)
+
+func TestMarkdownExportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	text := func(value string) *string { return &value }
+	tags := []string{"adr", "design"}
+	items := []db.ContextItem{
+		{ID: "plain", CreatedAt: 1700000000, Content: "no thread, no metadata", Importance: 3},
+		{ID: "first", CreatedAt: 1700000100, Namespace: text("work"), ThreadID: text("design"), Title: text("Storage"),
+			Content: "# Storage\n\nSQLite it is.\n\n---\n\n## Why\n\nOne file.", Tags: &tags, Importance: 5},
+		{ID: "second", CreatedAt: 1700000200, Namespace: text("work"), ThreadID: text("design"), Source: text("chat"),
+			Role: text("assistant"), Title: text("Follow-up: \"quotes\""), Content: "Keep the WAL.", Importance: 2},
+	}
+
+	source, err := db.Open(filepath.Join(t.TempDir(), "vcontext.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer source.Close()
+	if err := source.InsertContexts(ctx, items); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	writer, err := export.NewMarkdownWriter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := source.EachContext(ctx, db.ListFilter{}, true, writer.Write); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	target, err := db.Open(filepath.Join(t.TempDir(), "vcontext.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	summary, err := Run(ctx, target, []string{dir}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Created != len(items) || summary.Failed != 0 {
+		t.Fatalf("import = %+v, want %d created", summary, len(items))
+	}
+
+	for _, want := range items {
+		got, err := target.GetContext(ctx, want.ID)
+		if err != nil {
+			t.Fatalf("get %s: %v", want.ID, err)
+		}
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Fatalf("imported %s\n got %s\nwant %s", want.ID, gotJSON, wantJSON)
+		}
+	}
+
+	// Importing the export again finds every id already stored.
+	summary, err = Run(ctx, target, []string{dir}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Created != 0 || summary.Skipped != len(items) {
+		t.Fatalf("second import = %+v, want all %d skipped", summary, len(items))
+	}
+}
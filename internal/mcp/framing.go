@@ -0,0 +1,252 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+type Framing int
+
+const (
+	FramingLine Framing = iota
+	FramingContentLength
+)
+
+// maxHeaderLine bounds a header line of the content-length framing.
+const maxHeaderLine = 4096
+
+var errMessageTooLarge = errors.New("message too large")
+
+// frameError reports a message whose framing was malformed. The codec has
+// skipped it, so the stream can go on with the next message.
+type frameError struct {
+	reason string
+}
+
+func (e *frameError) Error() string {
+	return e.reason
+}
+
+func ParseFraming(value string) (Framing, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "line", "newline":
+		return FramingLine, nil
+	case "content-length", "header", "lsp":
+		return FramingContentLength, nil
+	default:
+		return FramingLine, fmt.Errorf("unknown framing %q (want line or content-length)", value)
+	}
+}
+
+type codec interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(data []byte) error
+}
+
+func newCodec(framing Framing, r io.Reader, w io.Writer, limit int) codec {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	if framing == FramingContentLength {
+		return &headerCodec{reader: reader, writer: w, limit: limit}
+	}
+	return &lineCodec{reader: reader, writer: w, limit: limit}
+}
+
+type lineCodec struct {
+	reader *bufio.Reader
+	writer io.Writer
+	limit  int
+}
+
+func (c *lineCodec) ReadMessage() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := c.reader.ReadSlice('\n')
+		if len(line)+len(chunk) > c.limit {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				if discardErr := discardLine(c.reader); discardErr != nil && !errors.Is(discardErr, io.EOF) {
+					return nil, discardErr
+				}
+			}
+			return nil, errMessageTooLarge
+		}
+		line = append(line, chunk...)
+
+		switch {
+		case err == nil:
+			return line, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case errors.Is(err, io.EOF) && len(bytes.TrimSpace(line)) > 0:
+			return line, nil
+		default:
+			return nil, err
+		}
+	}
+}
+
+// discardLine skips the rest of the current line.
+func discardLine(reader *bufio.Reader) error {
+	for {
+		_, err := reader.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return err
+	}
+}
+
+func (c *lineCodec) WriteMessage(data []byte) error {
+	data = append(data, '\n')
+	_, err := c.writer.Write(data)
+	return err
+}
+
+type headerCodec struct {
+	reader *bufio.Reader
+	writer io.Writer
+	limit  int
+	// pending is a header line found glued to the end of a skipped body;
+	// it starts the next message.
+	pending string
+}
+
+// ReadMessage reads one header block and its body. A malformed header
+// block is skipped up to its terminating blank line, and its body too when
+// the length is known, and reported as a frameError.
+func (c *headerCodec) ReadMessage() ([]byte, error) {
+	length := -1
+	var bad *frameError
+	for {
+		line, err := c.readHeaderLine()
+		if errors.Is(err, errHeaderTooLong) {
+			if bad == nil {
+				bad = &frameError{reason: fmt.Sprintf("header line exceeds %d bytes", maxHeaderLine)}
+			}
+			continue
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) && strings.TrimSpace(line) != "" {
+				return nil, io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if length < 0 && bad == nil {
+				continue
+			}
+			break
+		}
+
+		name, value, ok := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if !ok || !validHeaderName(name) {
+			// Bodies do not end with a newline, so the header of the next
+			// message follows the body of one that could not be read.
+			if i := strings.LastIndex(strings.ToLower(line), "content-length:"); i > 0 && bad == nil && length < 0 {
+				c.pending = line[i:] + "\n"
+				return nil, &frameError{reason: "unexpected data before header"}
+			}
+			if bad == nil {
+				bad = &frameError{reason: fmt.Sprintf("malformed header %q", truncate(line, 64))}
+			}
+			continue
+		}
+		if strings.EqualFold(name, "Content-Length") {
+			parsed, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || parsed < 0 {
+				if bad == nil {
+					bad = &frameError{reason: fmt.Sprintf("invalid Content-Length %q", truncate(strings.TrimSpace(value), 64))}
+				}
+				length = -1
+				continue
+			}
+			length = parsed
+		}
+	}
+
+	if bad != nil || length > c.limit {
+		if length >= 0 {
+			if _, err := io.CopyN(io.Discard, c.reader, int64(length)); err != nil {
+				return nil, err
+			}
+		}
+		if bad != nil {
+			return nil, bad
+		}
+		return nil, errMessageTooLarge
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// validHeaderName reports whether name is an HTTP token, as header names
+// must be.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case strings.ContainsRune("!#$%&'*+-.^_`|~", r):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	return text[:max] + "..."
+}
+
+var errHeaderTooLong = errors.New("header line too long")
+
+// readHeaderLine reads a line of at most maxHeaderLine bytes. A longer
+// line is consumed and reported as errHeaderTooLong.
+func (c *headerCodec) readHeaderLine() (string, error) {
+	if c.pending != "" {
+		line := c.pending
+		c.pending = ""
+		return line, nil
+	}
+	var line []byte
+	for {
+		chunk, err := c.reader.ReadSlice('\n')
+		if len(line)+len(chunk) > maxHeaderLine {
+			if errors.Is(err, bufio.ErrBufferFull) {
+				if discardErr := discardLine(c.reader); discardErr != nil && !errors.Is(discardErr, io.EOF) {
+					return "", discardErr
+				}
+			}
+			return "", errHeaderTooLong
+		}
+		line = append(line, chunk...)
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			return string(line), err
+		}
+	}
+}
+
+func (c *headerCodec) WriteMessage(data []byte) error {
+	header := "Content-Length: " + strconv.Itoa(len(data)) + "\r\n\r\n"
+	if _, err := io.WriteString(c.writer, header); err != nil {
+		return err
+	}
+	_, err := c.writer.Write(data)
+	return err
+}
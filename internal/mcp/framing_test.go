@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestHeaderFramingRecovers(t *testing.T) {
+	ping := func(id int) string {
+		body := fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":"ping"}`, id)
+		return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	input := ping(1) +
+		// The body of a message with an unreadable length runs into the
+		// header of the next one.
+		"Content-Length: many\r\n\r\n" + `{"jsonrpc":"2.0","id":9,"method":"ping"}` +
+		ping(2) +
+		"garbage\r\n" + ping(3) +
+		"X-Padding: " + strings.Repeat("x", 2*maxHeaderLine) + "\r\n" + ping(4) +
+		ping(5)
+
+	var output bytes.Buffer
+	server := NewServer(nil)
+	server.SetFraming(FramingContentLength)
+	if err := server.Serve(context.Background(), strings.NewReader(input), &output); err != nil {
+		t.Fatalf("serve: %v", err)
+	}
+
+	want := []string{
+		"1",
+		"parse error: invalid Content-Length \"many\"",
+		"parse error: unexpected data before header",
+		"2",
+		"parse error: malformed header \"garbage\"",
+		fmt.Sprintf("parse error: header line exceeds %d bytes", maxHeaderLine),
+		"5",
+	}
+	reader := &headerCodec{reader: bufio.NewReader(&output), limit: defaultMaxMessageBytes}
+	for i, expected := range want {
+		data, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatalf("response %d: %v", i+1, err)
+		}
+		var resp JSONRPCResponse
+		if err := json.Unmarshal(data, &resp); err != nil {
+			t.Fatalf("response %d: %v", i+1, err)
+		}
+		got := string(resp.ID)
+		if resp.Error != nil {
+			got = resp.Error.Message
+		}
+		if got != expected {
+			t.Fatalf("response %d = %s, want %s", i+1, got, expected)
+		}
+	}
+	if data, err := reader.ReadMessage(); !errors.Is(err, io.EOF) {
+		t.Fatalf("unexpected response %s, %v", data, err)
+	}
+}
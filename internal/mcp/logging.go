@@ -0,0 +1,162 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+var loggingLevels = map[string]slog.Level{
+	"debug":     slog.LevelDebug,
+	"info":      slog.LevelInfo,
+	"notice":    slog.LevelInfo + 2,
+	"warning":   slog.LevelWarn,
+	"error":     slog.LevelError,
+	"critical":  slog.LevelError + 4,
+	"alert":     slog.LevelError + 8,
+	"emergency": slog.LevelError + 12,
+}
+
+type SetLevelParams struct {
+	Level string `json:"level"`
+}
+
+type LoggingMessageParams struct {
+	Level  string `json:"level"`
+	Logger string `json:"logger,omitempty"`
+	Data   any    `json:"data"`
+}
+
+type clientLogLevel struct {
+	mu      sync.RWMutex
+	enabled bool
+	level   slog.Level
+}
+
+func (c *clientLogLevel) set(level slog.Level) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = true
+	c.level = level
+}
+
+func (c *clientLogLevel) allows(level slog.Level) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.enabled && level >= c.level
+}
+
+func (s *Server) handleSetLevel(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+	var input SetLevelParams
+	if len(params) == 0 {
+		return nil, NewError(ErrInvalidParams, "params are required")
+	}
+	if err := json.Unmarshal(params, &input); err != nil {
+		return nil, NewError(ErrInvalidParams, "invalid params")
+	}
+
+	level, ok := loggingLevels[strings.ToLower(strings.TrimSpace(input.Level))]
+	if !ok {
+		return nil, InvalidField("level", "enum", "unknown log level: "+input.Level)
+	}
+	s.clientLevel.set(level)
+	return struct{}{}, nil
+}
+
+func loggingLevelName(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError+12:
+		return "emergency"
+	case level >= slog.LevelError+8:
+		return "alert"
+	case level >= slog.LevelError+4:
+		return "critical"
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warning"
+	case level >= slog.LevelInfo+2:
+		return "notice"
+	case level >= slog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+type notifyHandler struct {
+	next   slog.Handler
+	server *Server
+	attrs  []slog.Attr
+	group  string
+}
+
+func (h *notifyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level) || h.server.clientLevel.allows(level)
+}
+
+func (h *notifyHandler) Handle(ctx context.Context, record slog.Record) error {
+	var err error
+	if h.next.Enabled(ctx, record.Level) {
+		err = h.next.Handle(ctx, record)
+	}
+
+	if h.server.clientLevel.allows(record.Level) {
+		data := map[string]any{"message": record.Message}
+		for _, attr := range h.attrs {
+			addAttr(data, "", attr)
+		}
+		record.Attrs(func(attr slog.Attr) bool {
+			addAttr(data, h.group, attr)
+			return true
+		})
+		h.server.notify("notifications/message", LoggingMessageParams{
+			Level:  loggingLevelName(record.Level),
+			Logger: h.server.info.Name,
+			Data:   data,
+		})
+	}
+
+	return err
+}
+
+func (h *notifyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	prefixed := make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	prefixed = append(prefixed, h.attrs...)
+	for _, attr := range attrs {
+		if h.group != "" {
+			attr.Key = h.group + "." + attr.Key
+		}
+		prefixed = append(prefixed, attr)
+	}
+	return &notifyHandler{next: h.next.WithAttrs(attrs), server: h.server, attrs: prefixed, group: h.group}
+}
+
+func (h *notifyHandler) WithGroup(name string) slog.Handler {
+	group := name
+	if h.group != "" {
+		group = h.group + "." + name
+	}
+	return &notifyHandler{next: h.next.WithGroup(name), server: h.server, attrs: h.attrs, group: group}
+}
+
+func addAttr(data map[string]any, prefix string, attr slog.Attr) {
+	key := attr.Key
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		for _, nested := range value.Group() {
+			addAttr(data, key, nested)
+		}
+		return
+	}
+	if err, ok := value.Any().(error); ok {
+		data[key] = err.Error()
+		return
+	}
+	data[key] = value.Any()
+}
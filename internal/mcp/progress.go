@@ -0,0 +1,61 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+)
+
+type progressKey struct{}
+
+type RequestMeta struct {
+	ProgressToken json.RawMessage `json:"progressToken,omitempty"`
+}
+
+type ProgressParams struct {
+	ProgressToken json.RawMessage `json:"progressToken"`
+	Progress      float64         `json:"progress"`
+	Total         float64         `json:"total,omitempty"`
+	Message       string          `json:"message,omitempty"`
+}
+
+type Progress struct {
+	server *Server
+	token  json.RawMessage
+}
+
+func ProgressFromContext(ctx context.Context) *Progress {
+	progress, _ := ctx.Value(progressKey{}).(*Progress)
+	return progress
+}
+
+func (p *Progress) Report(progress float64, total float64, message string) {
+	if p == nil {
+		return
+	}
+	p.server.notify("notifications/progress", ProgressParams{
+		ProgressToken: p.token,
+		Progress:      progress,
+		Total:         total,
+		Message:       message,
+	})
+}
+
+func (s *Server) withProgress(ctx context.Context, params json.RawMessage) context.Context {
+	if len(params) == 0 {
+		return ctx
+	}
+
+	var envelope struct {
+		Meta *RequestMeta `json:"_meta"`
+	}
+	if err := json.Unmarshal(params, &envelope); err != nil || envelope.Meta == nil {
+		return ctx
+	}
+
+	token := envelope.Meta.ProgressToken
+	if len(token) == 0 || string(token) == "null" {
+		return ctx
+	}
+
+	return context.WithValue(ctx, progressKey{}, &Progress{server: s, token: token})
+}
@@ -0,0 +1,190 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+const latestProtocolVersion = "2025-06-18"
+
+var supportedProtocolVersions = map[string]bool{
+	"2024-11-05":          true,
+	"2025-03-26":          true,
+	latestProtocolVersion: true,
+}
+
+type registeredTool struct {
+	tool    Tool
+	handler Handler
+}
+
+type PromptHandler func(ctx context.Context, args map[string]string) (*GetPromptResult, *RPCError)
+
+type registeredPrompt struct {
+	prompt  Prompt
+	handler PromptHandler
+}
+
+func (s *Server) SetInfo(info ServerInfo) {
+	s.info = info
+}
+
+// SetInstructions sets the source of the instructions sent in reply to
+// initialize. It is called for every initialize, so the text may change
+// while the server runs.
+func (s *Server) SetInstructions(instructions func() string) {
+	s.instructions = instructions
+}
+
+func (s *Server) RegisterTool(tool Tool, handler Handler) {
+	s.tools[tool.Name] = registeredTool{tool: tool, handler: handler}
+	s.handlers["tools/"+tool.Name+"/invoke"] = handler
+}
+
+func (s *Server) RegisterPrompt(prompt Prompt, handler PromptHandler) {
+	s.prompts[prompt.Name] = registeredPrompt{prompt: prompt, handler: handler}
+}
+
+func (s *Server) registerProtocol() {
+	s.handlers["initialize"] = s.handleInitialize
+	s.handlers["ping"] = s.handlePing
+	s.handlers["tools/list"] = s.handleListTools
+	s.handlers["tools/call"] = s.handleCallTool
+	s.handlers["logging/setLevel"] = s.handleSetLevel
+	s.handlers["prompts/list"] = s.handleListPrompts
+	s.handlers["prompts/get"] = s.handleGetPrompt
+}
+
+func (s *Server) handleInitialize(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+	var input InitializeParams
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &input); err != nil {
+			return nil, NewError(ErrInvalidParams, "invalid params")
+		}
+	}
+
+	version := latestProtocolVersion
+	if supportedProtocolVersions[input.ProtocolVersion] {
+		version = input.ProtocolVersion
+	}
+
+	capabilities := map[string]any{
+		"logging": map[string]any{},
+	}
+	if len(s.tools) > 0 {
+		capabilities["tools"] = map[string]any{"listChanged": false}
+	}
+	if len(s.prompts) > 0 {
+		capabilities["prompts"] = map[string]any{"listChanged": false}
+	}
+
+	result := InitializeResult{
+		ProtocolVersion: version,
+		Capabilities:    capabilities,
+		ServerInfo:      s.info,
+	}
+	if s.instructions != nil {
+		result.Instructions = s.instructions()
+	}
+	return result, nil
+}
+
+func (s *Server) handlePing(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+	return struct{}{}, nil
+}
+
+func (s *Server) handleListTools(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+	tools := make([]Tool, 0, len(s.tools))
+	for _, registered := range s.tools {
+		tools = append(tools, registered.tool)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].Name < tools[j].Name
+	})
+	return ListToolsResult{Tools: tools}, nil
+}
+
+func (s *Server) handleCallTool(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+	var input CallToolParams
+	if len(params) == 0 {
+		return nil, NewError(ErrInvalidParams, "params are required")
+	}
+	if err := json.Unmarshal(params, &input); err != nil {
+		return nil, NewError(ErrInvalidParams, "invalid params")
+	}
+
+	registered, ok := s.tools[strings.TrimSpace(input.Name)]
+	if !ok {
+		return nil, NewError(ErrInvalidParams, "unknown tool: "+input.Name)
+	}
+
+	args := input.Arguments
+	if len(args) == 0 || strings.TrimSpace(string(args)) == "null" {
+		args = json.RawMessage("{}")
+	}
+
+	result, rpcErr := registered.handler(ctx, args)
+	if rpcErr != nil {
+		if rpcErr.isProtocol() {
+			return nil, rpcErr
+		}
+		if rpcErr.cause != nil {
+			s.logError(rpcErr.cause, "tools/call "+input.Name)
+		}
+		return ToolResult{
+			Content:           []TextContent{NewTextContent(rpcErr.Message)},
+			StructuredContent: map[string]any{"error": rpcErr},
+			IsError:           true,
+		}, nil
+	}
+
+	text, err := json.Marshal(result)
+	if err != nil {
+		return nil, NewError(ErrInternal, "encode tool result").WithCause(err)
+	}
+
+	return ToolResult{
+		Content:           []TextContent{NewTextContent(string(text))},
+		StructuredContent: result,
+	}, nil
+}
+
+func (s *Server) handleListPrompts(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+	prompts := make([]Prompt, 0, len(s.prompts))
+	for _, registered := range s.prompts {
+		prompts = append(prompts, registered.prompt)
+	}
+	sort.Slice(prompts, func(i, j int) bool {
+		return prompts[i].Name < prompts[j].Name
+	})
+	return ListPromptsResult{Prompts: prompts}, nil
+}
+
+func (s *Server) handleGetPrompt(ctx context.Context, params json.RawMessage) (any, *RPCError) {
+	var input GetPromptParams
+	if len(params) == 0 {
+		return nil, NewError(ErrInvalidParams, "params are required")
+	}
+	if err := json.Unmarshal(params, &input); err != nil {
+		return nil, NewError(ErrInvalidParams, "invalid params")
+	}
+
+	registered, ok := s.prompts[strings.TrimSpace(input.Name)]
+	if !ok {
+		return nil, NewError(ErrInvalidParams, "unknown prompt: "+input.Name)
+	}
+
+	args := input.Arguments
+	if args == nil {
+		args = map[string]string{}
+	}
+	for _, arg := range registered.prompt.Arguments {
+		if arg.Required && strings.TrimSpace(args[arg.Name]) == "" {
+			return nil, NewError(ErrInvalidParams, "missing required argument: "+arg.Name)
+		}
+	}
+
+	return registered.handler(ctx, args)
+}
@@ -1,67 +1,161 @@
 package mcp
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"sync"
 )
 
-const maxMessageBytes = 8 * 1024 * 1024
+const defaultMaxMessageBytes = 8 * 1024 * 1024
 
 type Handler func(ctx context.Context, params json.RawMessage) (any, *RPCError)
 
 type Server struct {
 	handlers map[string]Handler
-	logger   *log.Logger
+	tools    map[string]registeredTool
+	prompts  map[string]registeredPrompt
+	info     ServerInfo
+	logger   *slog.Logger
+
+	instructions func() string
+
+	clientLevel clientLogLevel
+
+	framing         Framing
+	maxMessageBytes int
+	writeMu         sync.Mutex
+	conn            codec
 }
 
-func NewServer(logger *log.Logger) *Server {
-	return &Server{
+func NewServer(logger *slog.Logger) *Server {
+	s := &Server{
 		handlers: make(map[string]Handler),
-		logger:   logger,
+		tools:    make(map[string]registeredTool),
+		prompts:  make(map[string]registeredPrompt),
+		info:     ServerInfo{Name: "vcontext", Version: "dev"},
+
+		maxMessageBytes: defaultMaxMessageBytes,
+	}
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
 	}
+	s.logger = slog.New(&notifyHandler{next: logger.Handler(), server: s})
+	s.registerProtocol()
+	return s
+}
+
+func (s *Server) Logger() *slog.Logger {
+	return s.logger
 }
 
 func (s *Server) Register(method string, handler Handler) {
 	s.handlers[method] = handler
 }
 
-func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
-	scanner := bufio.NewScanner(r)
-	scanner.Buffer(make([]byte, 0, 64*1024), maxMessageBytes)
-	encoder := json.NewEncoder(w)
+func (s *Server) SetFraming(framing Framing) {
+	s.framing = framing
+}
+
+func (s *Server) SetMaxMessageBytes(limit int) {
+	s.maxMessageBytes = limit
+}
 
-	for scanner.Scan() {
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	conn := newCodec(s.framing, r, w, s.maxMessageBytes)
+
+	s.writeMu.Lock()
+	s.conn = conn
+	s.writeMu.Unlock()
+	defer func() {
+		s.writeMu.Lock()
+		s.conn = nil
+		s.writeMu.Unlock()
+	}()
+
+	for {
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
-		line := bytes.TrimSpace(scanner.Bytes())
-		if len(line) == 0 {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			if errors.Is(err, errMessageTooLarge) {
+				s.logger.Warn("rejected oversized message", "limit_bytes", s.maxMessageBytes)
+				if err := s.write(&JSONRPCResponse{
+					JSONRPC: "2.0",
+					ID:      json.RawMessage("null"),
+					Error: NewErrorWithData(
+						ErrInvalidRequest,
+						fmt.Sprintf("message exceeds the %d byte limit", s.maxMessageBytes),
+						ErrorData{Constraint: "max_bytes"},
+					),
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			var frameErr *frameError
+			if errors.As(err, &frameErr) {
+				s.logger.Warn("skipped malformed message", "err", err)
+				if err := s.write(&JSONRPCResponse{
+					JSONRPC: "2.0",
+					ID:      json.RawMessage("null"),
+					Error:   NewError(ErrParse, "parse error: "+frameErr.reason),
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		msg = bytes.TrimSpace(msg)
+		if len(msg) == 0 {
 			continue
 		}
 
-		resp := s.handleLine(ctx, line)
+		resp := s.handleLine(ctx, msg)
 		if resp == nil {
 			continue
 		}
 
-		if err := encoder.Encode(resp); err != nil {
+		if err := s.write(resp); err != nil {
 			return err
 		}
 	}
+}
 
-	if err := scanner.Err(); err != nil {
+func (s *Server) write(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if s.conn == nil {
+		return errors.New("server is not serving")
+	}
+	return s.conn.WriteMessage(data)
+}
+
+func (s *Server) notify(method string, params any) {
+	// Errors are dropped: reporting them through the logger would loop back here.
+	_ = s.write(JSONRPCNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
 }
 
 func (s *Server) handleLine(ctx context.Context, line []byte) *JSONRPCResponse {
@@ -82,6 +176,8 @@ func (s *Server) handleLine(ctx context.Context, line []byte) *JSONRPCResponse {
 		}
 	}
 
+	s.logger.Debug("handle request", "method", req.Method)
+
 	handler, ok := s.handlers[req.Method]
 	if !ok {
 		if len(req.ID) == 0 {
@@ -94,7 +190,10 @@ func (s *Server) handleLine(ctx context.Context, line []byte) *JSONRPCResponse {
 		}
 	}
 
-	result, rpcErr := handler(ctx, req.Params)
+	result, rpcErr := handler(s.withProgress(ctx, req.Params), req.Params)
+	if rpcErr != nil && rpcErr.cause != nil {
+		s.logError(rpcErr.cause, req.Method)
+	}
 	if len(req.ID) == 0 {
 		return nil
 	}
@@ -128,5 +227,5 @@ func (s *Server) logError(err error, message string) {
 	if errors.Is(err, context.Canceled) {
 		return
 	}
-	s.logger.Printf("%s: %v", message, err)
+	s.logger.Error(message, "err", err)
 }
@@ -8,6 +8,9 @@ const (
 	ErrMethodNotFound = -32601
 	ErrInvalidParams  = -32602
 	ErrInternal       = -32603
+
+	ErrStorage  = -32000
+	ErrNotFound = -32004
 )
 
 type JSONRPCRequest struct {
@@ -24,11 +27,137 @@ type JSONRPCResponse struct {
 	Error   *RPCError       `json:"error,omitempty"`
 }
 
+type JSONRPCNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  any    `json:"params,omitempty"`
+}
+
 type RPCError struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+	cause   error
+}
+
+type ErrorData struct {
+	Field      string `json:"field,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
 }
 
 func NewError(code int, message string) *RPCError {
 	return &RPCError{Code: code, Message: message}
 }
+
+func NewErrorWithData(code int, message string, data any) *RPCError {
+	return &RPCError{Code: code, Message: message, Data: data}
+}
+
+func InvalidField(field string, constraint string, message string) *RPCError {
+	return NewErrorWithData(ErrInvalidParams, message, ErrorData{Field: field, Constraint: constraint})
+}
+
+func StorageError(action string, err error) *RPCError {
+	return NewError(ErrStorage, "could not "+action+": the memory store is unavailable, try again later").WithCause(err)
+}
+
+func (e *RPCError) WithCause(err error) *RPCError {
+	e.cause = err
+	return e
+}
+
+func (e *RPCError) Cause() error {
+	return e.cause
+}
+
+// isProtocol reports whether the error describes a malformed call rather than
+// a failure of the tool itself; argument decoding errors carry no data.
+func (e *RPCError) isProtocol() bool {
+	switch e.Code {
+	case ErrParse, ErrInvalidRequest, ErrMethodNotFound:
+		return true
+	case ErrInvalidParams:
+		return e.Data == nil
+	default:
+		return false
+	}
+}
+
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type InitializeParams struct {
+	ProtocolVersion string          `json:"protocolVersion"`
+	Capabilities    json.RawMessage `json:"capabilities,omitempty"`
+	ClientInfo      *ServerInfo     `json:"clientInfo,omitempty"`
+}
+
+type InitializeResult struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ServerInfo      ServerInfo     `json:"serverInfo"`
+	Instructions    string         `json:"instructions,omitempty"`
+}
+
+type TextContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+func NewTextContent(text string) TextContent {
+	return TextContent{Type: "text", Text: text}
+}
+
+type Prompt struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Arguments   []PromptArgument `json:"arguments,omitempty"`
+}
+
+type PromptArgument struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type PromptMessage struct {
+	Role    string      `json:"role"`
+	Content TextContent `json:"content"`
+}
+
+type ListPromptsResult struct {
+	Prompts []Prompt `json:"prompts"`
+}
+
+type GetPromptParams struct {
+	Name      string            `json:"name"`
+	Arguments map[string]string `json:"arguments,omitempty"`
+}
+
+type GetPromptResult struct {
+	Description string          `json:"description,omitempty"`
+	Messages    []PromptMessage `json:"messages"`
+}
+
+type Tool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+type ListToolsResult struct {
+	Tools []Tool `json:"tools"`
+}
+
+type CallToolParams struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments,omitempty"`
+}
+
+type ToolResult struct {
+	Content           []TextContent `json:"content"`
+	StructuredContent any           `json:"structuredContent,omitempty"`
+	IsError           bool          `json:"isError,omitempty"`
+}
@@ -0,0 +1,232 @@
+package prompts
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"vcontext/internal/common"
+	"vcontext/internal/db"
+	"vcontext/internal/mcp"
+)
+
+const (
+	defaultRecallTopK   = 8
+	maxRecallTopK       = 50
+	defaultReviewLimit  = 30
+	maxReviewLimit      = 200
+	defaultSessionLimit = 20
+)
+
+const (
+	recallName          = "recall"
+	rememberSessionName = "remember_session"
+	reviewThreadName    = "review_thread"
+)
+
+// builtinNames are the prompts a template may not replace.
+var builtinNames = map[string]bool{
+	recallName:          true,
+	rememberSessionName: true,
+	reviewThreadName:    true,
+}
+
+func RecallPrompt(store *db.DB) (mcp.Prompt, mcp.PromptHandler) {
+	prompt := mcp.Prompt{
+		Name:        recallName,
+		Description: "Search long-term memory before answering and ground the answer in what was found.",
+		Arguments: []mcp.PromptArgument{
+			{Name: "query", Description: "what to look up in memory", Required: true},
+			{Name: "thread_id", Description: "restrict the search to one thread"},
+			{Name: "top_k", Description: "maximum number of memories to include"},
+		},
+	}
+
+	handler := func(ctx context.Context, args map[string]string) (*mcp.GetPromptResult, *mcp.RPCError) {
+		query := strings.TrimSpace(args["query"])
+		topK, rpcErr := intArg(args, "top_k", defaultRecallTopK, maxRecallTopK)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+
+		var results []db.SearchResult
+		// The query is a question in plain words, not FTS5 syntax.
+		if match := db.MatchAnyWord(query); match != "" {
+			var err error
+			results, err = store.SearchContext(ctx, match, db.SearchFilter{
+				TopK:          topK,
+				ThreadID:      optionalArg(args, "thread_id"),
+				MinImportance: 1,
+			})
+			if err != nil {
+				return nil, mcp.StorageError("search memory", err)
+			}
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "Before answering, use the memory below that was retrieved for %q.\n", query)
+		b.WriteString("Call get_context with an id when a snippet is not enough, and say so when memory does not cover the question.\n\n")
+		if len(results) == 0 {
+			b.WriteString("No stored memories matched this query.\n")
+		} else {
+			b.WriteString("Relevant memories:\n")
+			for _, result := range results {
+				fmt.Fprintf(&b, "- [%s] %s (importance %d, %s): %s\n",
+					result.ID,
+					valueOr(result.Title, "untitled"),
+					result.Importance,
+					formatTime(result.CreatedAt),
+					result.Snippet,
+				)
+			}
+		}
+
+		return &mcp.GetPromptResult{
+			Description: "Recall memories for " + query,
+			Messages:    []mcp.PromptMessage{userMessage(b.String())},
+		}, nil
+	}
+
+	return prompt, handler
+}
+
+func RememberSessionPrompt(store *db.DB) (mcp.Prompt, mcp.PromptHandler) {
+	prompt := mcp.Prompt{
+		Name:        rememberSessionName,
+		Description: "Summarize the current session into durable memories saved with save_context.",
+		Arguments: []mcp.PromptArgument{
+			{Name: "thread_id", Description: "thread to attach the new memories to"},
+			{Name: "focus", Description: "what the summary should concentrate on"},
+		},
+	}
+
+	handler := func(ctx context.Context, args map[string]string) (*mcp.GetPromptResult, *mcp.RPCError) {
+		threadID := optionalArg(args, "thread_id")
+
+		var b strings.Builder
+		b.WriteString("Summarize this session into durable memories and store each one with save_context.\n\n")
+		b.WriteString("Guidelines:\n")
+		b.WriteString("- Save decisions, facts, preferences and open questions; skip small talk and transient details.\n")
+		b.WriteString("- Keep one idea per memory with a short descriptive title and a few lowercase tags.\n")
+		b.WriteString("- Use importance 1-5: 5 for decisions that must not be forgotten, 1 for minor notes.\n")
+		if threadID != nil {
+			fmt.Fprintf(&b, "- Set thread_id to %q on every memory.\n", *threadID)
+		}
+		if focus := strings.TrimSpace(args["focus"]); focus != "" {
+			fmt.Fprintf(&b, "- Concentrate on: %s\n", focus)
+		}
+
+		if threadID != nil {
+			items, err := store.RecentContext(ctx, threadID, defaultSessionLimit)
+			if err != nil {
+				return nil, mcp.StorageError("load thread", err)
+			}
+			if len(items) > 0 {
+				b.WriteString("\nThe thread already contains these memories; do not save duplicates:\n")
+				for _, item := range items {
+					fmt.Fprintf(&b, "- [%s] %s\n", item.ID, valueOr(item.Title, firstLine(item.Content)))
+				}
+			}
+		}
+
+		return &mcp.GetPromptResult{
+			Description: "Save the session as durable memories",
+			Messages:    []mcp.PromptMessage{userMessage(b.String())},
+		}, nil
+	}
+
+	return prompt, handler
+}
+
+func ReviewThreadPrompt(store *db.DB) (mcp.Prompt, mcp.PromptHandler) {
+	prompt := mcp.Prompt{
+		Name:        reviewThreadName,
+		Description: "Review the stored memories of a thread and point out gaps, conflicts and stale entries.",
+		Arguments: []mcp.PromptArgument{
+			{Name: "thread_id", Description: "thread to review", Required: true},
+			{Name: "limit", Description: "maximum number of recent memories to include"},
+		},
+	}
+
+	handler := func(ctx context.Context, args map[string]string) (*mcp.GetPromptResult, *mcp.RPCError) {
+		threadID := optionalArg(args, "thread_id")
+		limit, rpcErr := intArg(args, "limit", defaultReviewLimit, maxReviewLimit)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+
+		items, err := store.RecentContext(ctx, threadID, limit)
+		if err != nil {
+			return nil, mcp.StorageError("load thread", err)
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "Review the memories stored for thread %q, oldest first.\n", *threadID)
+		b.WriteString("Summarize what the thread is about, list conflicting or outdated entries, and suggest what should be saved or re-prioritized.\n\n")
+		if len(items) == 0 {
+			b.WriteString("The thread has no stored memories.\n")
+		}
+		for i := len(items) - 1; i >= 0; i-- {
+			item := items[i]
+			fmt.Fprintf(&b, "### %s\n", valueOr(item.Title, "untitled"))
+			fmt.Fprintf(&b, "id: %s | role: %s | importance: %d | %s\n\n", item.ID, valueOr(item.Role, "-"), item.Importance, formatTime(item.CreatedAt))
+			b.WriteString(strings.TrimSpace(item.Content))
+			b.WriteString("\n\n")
+		}
+
+		return &mcp.GetPromptResult{
+			Description: "Review thread " + *threadID,
+			Messages:    []mcp.PromptMessage{userMessage(b.String())},
+		}, nil
+	}
+
+	return prompt, handler
+}
+
+func userMessage(text string) mcp.PromptMessage {
+	return mcp.PromptMessage{Role: "user", Content: mcp.NewTextContent(text)}
+}
+
+func optionalArg(args map[string]string, name string) *string {
+	value := strings.TrimSpace(args[name])
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func intArg(args map[string]string, name string, fallback int, max int) (int, *mcp.RPCError) {
+	raw := strings.TrimSpace(args[name])
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, mcp.NewError(mcp.ErrInvalidParams, name+" must be an integer")
+	}
+	return common.ClampInt(value, 1, max), nil
+}
+
+func valueOr(value *string, fallback string) string {
+	if value == nil || strings.TrimSpace(*value) == "" {
+		return fallback
+	}
+	return *value
+}
+
+func firstLine(text string) string {
+	text = strings.TrimSpace(text)
+	if idx := strings.IndexByte(text, '\n'); idx >= 0 {
+		text = text[:idx]
+	}
+	if runes := []rune(text); len(runes) > 80 {
+		text = string(runes[:80]) + "..."
+	}
+	return text
+}
+
+func formatTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format("2006-01-02 15:04")
+}
@@ -0,0 +1,194 @@
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"vcontext/internal/db"
+	"vcontext/internal/mcp"
+)
+
+var templateExtensions = map[string]bool{
+	".md":   true,
+	".tmpl": true,
+	".txt":  true,
+}
+
+var promptNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+type Template struct {
+	Prompt mcp.Prompt
+	Path   string
+	body   *template.Template
+}
+
+// LoadTemplates reads the prompt templates in dir. A file that does not
+// parse, or whose name is taken by a built-in prompt or an earlier file, is
+// skipped with a warning so the others still load.
+func LoadTemplates(dir string, logger *slog.Logger) ([]Template, error) {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read prompts dir: %w", err)
+	}
+
+	templates := make([]Template, 0, len(entries))
+	loaded := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() || !templateExtensions[strings.ToLower(filepath.Ext(entry.Name()))] {
+			continue
+		}
+		tmpl, err := loadTemplate(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			logger.Warn("skipping prompt template", "err", err)
+			continue
+		}
+		name := tmpl.Prompt.Name
+		if builtinNames[name] {
+			logger.Warn("skipping prompt template: name taken by a built-in prompt", "path", tmpl.Path, "prompt", name)
+			continue
+		}
+		if first, ok := loaded[name]; ok {
+			logger.Warn("skipping prompt template: name already loaded", "path", tmpl.Path, "prompt", name, "loaded", first)
+			continue
+		}
+		loaded[name] = tmpl.Path
+		templates = append(templates, tmpl)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Prompt.Name < templates[j].Prompt.Name
+	})
+	return templates, nil
+}
+
+func loadTemplate(path string) (Template, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Template{}, fmt.Errorf("read prompt %s: %w", path, err)
+	}
+
+	base := filepath.Base(path)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	if !promptNamePattern.MatchString(name) {
+		return Template{}, fmt.Errorf("prompt %s: invalid name %q", path, name)
+	}
+
+	meta, body := splitFrontMatter(string(data))
+	prompt := mcp.Prompt{
+		Name:        name,
+		Description: meta["description"],
+	}
+	for _, raw := range strings.Split(meta["arguments"], ",") {
+		arg := strings.TrimSpace(raw)
+		if arg == "" {
+			continue
+		}
+		required := !strings.HasSuffix(arg, "?")
+		prompt.Arguments = append(prompt.Arguments, mcp.PromptArgument{
+			Name:     strings.TrimSuffix(arg, "?"),
+			Required: required,
+		})
+	}
+
+	parsed, err := template.New(name).Funcs(templateStubs).Option("missingkey=zero").Parse(body)
+	if err != nil {
+		return Template{}, fmt.Errorf("prompt %s: %w", path, err)
+	}
+
+	return Template{Prompt: prompt, Path: path, body: parsed}, nil
+}
+
+func (t Template) Handler(store *db.DB) mcp.PromptHandler {
+	return func(ctx context.Context, args map[string]string) (*mcp.GetPromptResult, *mcp.RPCError) {
+		tmpl, err := t.body.Clone()
+		if err != nil {
+			return nil, mcp.NewError(mcp.ErrInternal, "prepare prompt "+t.Prompt.Name).WithCause(err)
+		}
+		tmpl.Funcs(storeFuncs(ctx, store))
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, args); err != nil {
+			return nil, mcp.NewError(mcp.ErrInternal, "could not render prompt "+t.Prompt.Name).WithCause(err)
+		}
+
+		return &mcp.GetPromptResult{
+			Description: t.Prompt.Description,
+			Messages:    []mcp.PromptMessage{userMessage(strings.TrimSpace(buf.String()))},
+		}, nil
+	}
+}
+
+var templateStubs = template.FuncMap{
+	"search": func(query string, topK int) ([]db.SearchResult, error) { return nil, nil },
+	"recent": func(threadID string, limit int) ([]db.ContextItem, error) { return nil, nil },
+	"deref":  deref,
+}
+
+func storeFuncs(ctx context.Context, store *db.DB) template.FuncMap {
+	return template.FuncMap{
+		"search": func(query string, topK int) ([]db.SearchResult, error) {
+			match := db.MatchAnyWord(query)
+			if match == "" {
+				return nil, nil
+			}
+			return store.SearchContext(ctx, match, db.SearchFilter{TopK: topK, MinImportance: 1})
+		},
+		"recent": func(threadID string, limit int) ([]db.ContextItem, error) {
+			var thread *string
+			if strings.TrimSpace(threadID) != "" {
+				thread = &threadID
+			}
+			return store.RecentContext(ctx, thread, limit)
+		},
+	}
+}
+
+func deref(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func splitFrontMatter(text string) (map[string]string, string) {
+	meta := map[string]string{}
+	normalized := strings.ReplaceAll(text, "\r\n", "\n")
+	if !strings.HasPrefix(normalized, "---\n") {
+		return meta, text
+	}
+
+	rest := normalized[len("---\n"):]
+	end := strings.Index(rest, "\n---")
+	if end < 0 {
+		return meta, text
+	}
+
+	for _, line := range strings.Split(rest[:end], "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		meta[strings.ToLower(strings.TrimSpace(key))] = strings.TrimSpace(value)
+	}
+
+	body := rest[end+len("\n---"):]
+	body = strings.TrimPrefix(body, "\n")
+	return meta, body
+}
@@ -0,0 +1,46 @@
+package prompts
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplatesSkipsBadFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"decisions.md":  "---\ndescription: decisions\narguments: topic\n---\n{{range search .topic 5}}{{.Snippet}}{{end}}",
+		"broken.md":     "{{range}",
+		"bad name.md":   "hello",
+		"recall.md":     "my own recall",
+		"decisions.txt": "a second decisions prompt",
+		"notes.tmpl":    "notes",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var logs bytes.Buffer
+	templates, err := LoadTemplates(dir, slog.New(slog.NewTextHandler(&logs, nil)))
+	if err != nil {
+		t.Fatalf("LoadTemplates: %v", err)
+	}
+
+	var names []string
+	for _, tmpl := range templates {
+		names = append(names, filepath.Base(tmpl.Path))
+	}
+	if got, want := strings.Join(names, " "), "decisions.md notes.tmpl"; got != want {
+		t.Fatalf("loaded %s, want %s", got, want)
+	}
+	for _, skipped := range []string{"broken.md", "bad name.md", "recall.md", "decisions.txt"} {
+		if !strings.Contains(logs.String(), skipped) {
+			t.Errorf("no warning about %s in:\n%s", skipped, logs.String())
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"vcontext/internal/db"
+	"vcontext/internal/mcp"
+)
+
+const (
+	bulkSaveBatchSize = 100
+	maxBulkSaveItems  = 10000
+)
+
+type BulkSaveContextParams struct {
+	Items []SaveContextParams `json:"items"`
+}
+
+type BulkSaveContextResult struct {
+	Items []SaveContextResult `json:"items"`
+}
+
+func BulkSaveContextTool(settings Settings) mcp.Tool {
+	return mcp.Tool{
+		Name:        "bulk_save_context",
+		Description: "Store many memories at once; each item takes the same fields as save_context.",
+		InputSchema: json.RawMessage(fmt.Sprintf(`{
+			"type": "object",
+			"properties": {
+				"items": {
+					"type": "array",
+					"maxItems": 10000,
+					"items": {
+						"type": "object",
+						"properties": {
+							"content": {"type": "string"},
+							"title": {"type": "string"},
+							"namespace": {"type": "string"},
+							"source": {"type": "string"},
+							"thread_id": {"type": "string"},
+							"role": {"type": "string"},
+							"tags": {"type": "array", "items": {"type": "string"}},
+							"importance": {"type": "integer", "default": %d}
+						},
+						"required": ["content"]
+					}
+				}
+			},
+			"required": ["items"]
+		}`, settings.DefaultImportance)),
+	}
+}
+
+func BulkSaveContextHandler(store *db.DB, settings Settings) mcp.Handler {
+	return func(ctx context.Context, params json.RawMessage) (any, *mcp.RPCError) {
+		var input BulkSaveContextParams
+		if err := decodeParams(params, &input); err != nil {
+			return nil, err
+		}
+
+		return BulkSaveContext(ctx, store, settings, input)
+	}
+}
+
+func BulkSaveContext(ctx context.Context, store *db.DB, settings Settings, input BulkSaveContextParams) (*BulkSaveContextResult, *mcp.RPCError) {
+	if len(input.Items) == 0 {
+		return nil, mcp.InvalidField("items", "required", "items must contain at least one item")
+	}
+	if len(input.Items) > maxBulkSaveItems {
+		return nil, mcp.InvalidField("items", "max_items", fmt.Sprintf("items must contain at most %d items", maxBulkSaveItems))
+	}
+
+	items := make([]db.ContextItem, 0, len(input.Items))
+	for i, raw := range input.Items {
+		item, rpcErr := newContextItem(settings, raw, fmt.Sprintf("items[%d].", i))
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		items = append(items, item)
+	}
+
+	progress := mcp.ProgressFromContext(ctx)
+	total := float64(len(items))
+	results := make([]SaveContextResult, 0, len(items))
+	for start := 0; start < len(items); start += bulkSaveBatchSize {
+		end := start + bulkSaveBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		if err := store.InsertContexts(ctx, items[start:end]); err != nil {
+			return nil, mcp.StorageError(fmt.Sprintf("save items %d-%d (earlier items were saved)", start, end-1), err)
+		}
+		for _, item := range items[start:end] {
+			results = append(results, SaveContextResult{ID: item.ID, CreatedAt: item.CreatedAt})
+		}
+		progress.Report(float64(end), total, fmt.Sprintf("saved %d of %d items", end, len(items)))
+	}
+
+	return &BulkSaveContextResult{Items: results}, nil
+}
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"strings"
 
 	"vcontext/internal/db"
@@ -13,26 +14,43 @@ type GetContextParams struct {
 	ID string `json:"id"`
 }
 
+func GetContextTool() mcp.Tool {
+	return mcp.Tool{
+		Name:        "get_context",
+		Description: "Fetch the full content of a memory by id.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"id": {"type": "string"}
+			},
+			"required": ["id"]
+		}`),
+	}
+}
+
 func GetContextHandler(store *db.DB) mcp.Handler {
 	return func(ctx context.Context, params json.RawMessage) (any, *mcp.RPCError) {
 		var input GetContextParams
 		if err := decodeParams(params, &input); err != nil {
 			return nil, err
 		}
+		return GetContext(ctx, store, input)
+	}
+}
 
-		id := strings.TrimSpace(input.ID)
-		if id == "" {
-			return nil, mcp.NewError(mcp.ErrInvalidParams, "id is required")
-		}
+func GetContext(ctx context.Context, store *db.DB, input GetContextParams) (*db.ContextItem, *mcp.RPCError) {
+	id := strings.TrimSpace(input.ID)
+	if id == "" {
+		return nil, mcp.InvalidField("id", "required", "id is required")
+	}
 
-		item, err := store.GetContext(ctx, id)
-		if err != nil {
-			if err == db.ErrNotFound {
-				return nil, mcp.NewError(-32004, "context item not found")
-			}
-			return nil, mcp.NewError(mcp.ErrInternal, err.Error())
+	item, err := store.GetContext(ctx, id)
+	if err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return nil, mcp.NewErrorWithData(mcp.ErrNotFound, "no memory exists with this id", mcp.ErrorData{Field: "id", Constraint: "exists"})
 		}
-
-		return item, nil
+		return nil, mcp.StorageError("get context", err)
 	}
+
+	return item, nil
 }
@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"vcontext/internal/common"
+	"vcontext/internal/db"
+	"vcontext/internal/mcp"
+)
+
+const (
+	defaultStatsTop  = 10
+	maxStatsTop      = 100
+	defaultStatsDays = 30
+	maxStatsDays     = 366
+)
+
+type MemoryStatsParams struct {
+	Namespace *string `json:"namespace"`
+	Top       *int    `json:"top"`
+	Days      *int    `json:"days"`
+}
+
+func MemoryStatsTool() mcp.Tool {
+	return mcp.Tool{
+		Name:        "memory_stats",
+		Description: "Summarize stored memories: counts by namespace, thread, source, role and tag, importance, activity per day, largest items and storage size.",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"namespace": {"type": "string"},
+				"top": {"type": "integer", "minimum": 1, "maximum": 100, "default": 10, "description": "entries per breakdown"},
+				"days": {"type": "integer", "minimum": 1, "maximum": 366, "default": 30, "description": "days covered by the per-day counts"}
+			}
+		}`),
+	}
+}
+
+func MemoryStatsHandler(store *db.DB, settings Settings) mcp.Handler {
+	return func(ctx context.Context, params json.RawMessage) (any, *mcp.RPCError) {
+		var input MemoryStatsParams
+		if trimmed := strings.TrimSpace(string(params)); trimmed != "" && trimmed != "null" {
+			if err := decodeParams(params, &input); err != nil {
+				return nil, err
+			}
+		}
+		return MemoryStats(ctx, store, settings, input)
+	}
+}
+
+func MemoryStats(ctx context.Context, store *db.DB, settings Settings, input MemoryStatsParams) (*db.Stats, *mcp.RPCError) {
+	top := defaultStatsTop
+	if input.Top != nil {
+		top = *input.Top
+	}
+	top = common.ClampInt(top, 1, maxStatsTop)
+
+	days := defaultStatsDays
+	if input.Days != nil {
+		days = *input.Days
+	}
+	days = common.ClampInt(days, 1, maxStatsDays)
+
+	namespace := input.Namespace
+	if namespace == nil {
+		namespace = settings.Namespace
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	stats, err := store.Stats(ctx, db.StatsFilter{
+		Namespace: namespace,
+		Top:       top,
+		Since:     today.AddDate(0, 0, 1-days).Unix(),
+	})
+	if err != nil {
+		return nil, mcp.StorageError("compute memory stats", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"vcontext/internal/db"
+)
+
+func TestMemoryStatsDefaultNamespace(t *testing.T) {
+	ctx := context.Background()
+	store, err := db.Open(filepath.Join(t.TempDir(), "vcontext.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	work, home := "work", "home"
+	if err := store.InsertContexts(ctx, []db.ContextItem{
+		{ID: "a", CreatedAt: 1, Namespace: &work, Content: "one", Importance: 3},
+		{ID: "b", CreatedAt: 2, Namespace: &work, Content: "two", Importance: 3},
+		{ID: "c", CreatedAt: 3, Namespace: &home, Content: "three", Importance: 3},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := DefaultSettings()
+	settings.Namespace = &work
+	for _, tc := range []struct {
+		name      string
+		settings  Settings
+		namespace *string
+		want      int
+	}{
+		{"no default", DefaultSettings(), nil, 3},
+		{"default namespace", settings, nil, 2},
+		{"requested namespace", settings, &home, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stats, rpcErr := MemoryStats(ctx, store, tc.settings, MemoryStatsParams{Namespace: tc.namespace})
+			if rpcErr != nil {
+				t.Fatal(rpcErr.Message)
+			}
+			if stats.Items != tc.want {
+				t.Fatalf("items = %d, want %d", stats.Items, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"vcontext/internal/db"
+	"vcontext/internal/mcp"
+)
+
+type ReindexResult struct {
+	Indexed int `json:"indexed"`
+}
+
+func ReindexTool() mcp.Tool {
+	return mcp.Tool{
+		Name:        "reindex",
+		Description: "Rebuild the full-text search index from stored memories.",
+		InputSchema: json.RawMessage(`{"type": "object", "properties": {}}`),
+	}
+}
+
+func ReindexHandler(store *db.DB) mcp.Handler {
+	return func(ctx context.Context, params json.RawMessage) (any, *mcp.RPCError) {
+		return Reindex(ctx, store)
+	}
+}
+
+func Reindex(ctx context.Context, store *db.DB) (*ReindexResult, *mcp.RPCError) {
+	progress := mcp.ProgressFromContext(ctx)
+	indexed, err := store.Reindex(ctx, func(done int, total int) {
+		progress.Report(float64(done), float64(total), fmt.Sprintf("indexed %d of %d items", done, total))
+	})
+	if err != nil {
+		return nil, mcp.StorageError("rebuild the search index", err)
+	}
+
+	return &ReindexResult{Indexed: indexed}, nil
+}
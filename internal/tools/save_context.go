@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 const defaultImportance = 3
 
 type SaveContextParams struct {
+	Namespace  *string   `json:"namespace"`
 	Source     *string   `json:"source"`
 	ThreadID   *string   `json:"thread_id"`
 	Role       *string   `json:"role"`
@@ -29,41 +32,96 @@ type SaveContextResult struct {
 	CreatedAt int64  `json:"created_at"`
 }
 
-func SaveContextHandler(store *db.DB) mcp.Handler {
+func SaveContextTool(settings Settings) mcp.Tool {
+	return mcp.Tool{
+		Name:        "save_context",
+		Description: "Store a piece of long-term memory such as a decision, fact or preference.",
+		InputSchema: json.RawMessage(fmt.Sprintf(`{
+			"type": "object",
+			"properties": {
+				"content": {"type": "string", "description": "text to remember"},
+				"title": {"type": "string"},
+				"namespace": {"type": "string", "description": "isolates memories of one project or agent"},
+				"source": {"type": "string"},
+				"thread_id": {"type": "string"},
+				"role": {"type": "string"},
+				"tags": {"type": "array", "items": {"type": "string"}},
+				"importance": {"type": "integer", "description": "1 (minor) to 5 (critical)", "default": %d}
+			},
+			"required": ["content"]
+		}`, settings.DefaultImportance)),
+	}
+}
+
+func SaveContextHandler(store *db.DB, settings Settings) mcp.Handler {
 	return func(ctx context.Context, params json.RawMessage) (any, *mcp.RPCError) {
 		var input SaveContextParams
 		if err := decodeParams(params, &input); err != nil {
 			return nil, err
 		}
+		return SaveContext(ctx, store, settings, input)
+	}
+}
 
-		if strings.TrimSpace(input.Content) == "" {
-			return nil, mcp.NewError(mcp.ErrInvalidParams, "content is required")
-		}
+func SaveContext(ctx context.Context, store *db.DB, settings Settings, input SaveContextParams) (*SaveContextResult, *mcp.RPCError) {
+	item, rpcErr := newContextItem(settings, input, "")
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
 
-		importance := defaultImportance
-		if input.Importance != nil {
-			importance = *input.Importance
-		}
+	if err := store.InsertContext(ctx, item); err != nil {
+		return nil, mcp.StorageError("save context", err)
+	}
 
-		item := db.ContextItem{
-			ID:         uuid.NewString(),
-			CreatedAt:  time.Now().Unix(),
-			Source:     input.Source,
-			ThreadID:   input.ThreadID,
-			Role:       input.Role,
-			Title:      input.Title,
-			Content:    input.Content,
-			Tags:       input.Tags,
-			Importance: importance,
-		}
+	return &SaveContextResult{
+		ID:        item.ID,
+		CreatedAt: item.CreatedAt,
+	}, nil
+}
 
-		if err := store.InsertContext(ctx, item); err != nil {
-			return nil, mcp.NewError(mcp.ErrInternal, err.Error())
-		}
+func newContextItem(settings Settings, input SaveContextParams, fieldPrefix string) (db.ContextItem, *mcp.RPCError) {
+	if strings.TrimSpace(input.Content) == "" {
+		return db.ContextItem{}, mcp.InvalidField(fieldPrefix+"content", "required", fieldPrefix+"content is required")
+	}
+
+	importance := settings.DefaultImportance
+	if input.Importance != nil {
+		importance = *input.Importance
+	}
 
-		return SaveContextResult{
-			ID:        item.ID,
-			CreatedAt: item.CreatedAt,
-		}, nil
+	namespace := input.Namespace
+	if namespace == nil {
+		namespace = settings.Namespace
+	}
+
+	return db.ContextItem{
+		ID:         uuid.NewString(),
+		CreatedAt:  time.Now().Unix(),
+		Namespace:  namespace,
+		Source:     input.Source,
+		ThreadID:   input.ThreadID,
+		Role:       input.Role,
+		Title:      input.Title,
+		Content:    input.Content,
+		Tags:       mergeTags(input.Tags, settings.Tags),
+		Importance: importance,
+	}, nil
+}
+
+// mergeTags appends the configured tags the item does not already carry.
+func mergeTags(tags *[]string, defaults []string) *[]string {
+	if len(defaults) == 0 {
+		return tags
+	}
+
+	merged := []string{}
+	if tags != nil {
+		merged = append(merged, *tags...)
+	}
+	for _, tag := range defaults {
+		if !slices.Contains(merged, tag) {
+			merged = append(merged, tag)
+		}
 	}
+	return &merged
 }
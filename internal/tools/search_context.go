@@ -3,6 +3,8 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"strings"
 
 	"vcontext/internal/common"
@@ -11,14 +13,15 @@ import (
 )
 
 const (
-	defaultTopK        = 5
-	maxTopK            = 50
+	defaultTopK          = 5
+	maxTopK              = 50
 	defaultMinImportance = 1
 )
 
 type SearchContextParams struct {
 	Query         string  `json:"query"`
 	TopK          *int    `json:"top_k"`
+	Namespace     *string `json:"namespace"`
 	ThreadID      *string `json:"thread_id"`
 	MinImportance *int    `json:"min_importance"`
 }
@@ -27,37 +30,71 @@ type SearchContextResult struct {
 	Items []db.SearchResult `json:"items"`
 }
 
-func SearchContextHandler(store *db.DB) mcp.Handler {
+func SearchContextTool(settings Settings) mcp.Tool {
+	return mcp.Tool{
+		Name:        "search_context",
+		Description: "Full-text search over long-term memory; returns ranked snippets.",
+		InputSchema: json.RawMessage(fmt.Sprintf(`{
+			"type": "object",
+			"properties": {
+				"query": {"type": "string", "description": "FTS5 query"},
+				"top_k": {"type": "integer", "minimum": 1, "maximum": %d, "default": %d},
+				"namespace": {"type": "string"},
+				"thread_id": {"type": "string"},
+				"min_importance": {"type": "integer", "minimum": 1, "default": 1}
+			},
+			"required": ["query"]
+		}`, settings.MaxTopK, settings.DefaultTopK)),
+	}
+}
+
+func SearchContextHandler(store *db.DB, settings Settings) mcp.Handler {
 	return func(ctx context.Context, params json.RawMessage) (any, *mcp.RPCError) {
 		var input SearchContextParams
 		if err := decodeParams(params, &input); err != nil {
 			return nil, err
 		}
+		return SearchContext(ctx, store, settings, input)
+	}
+}
 
-		query := strings.TrimSpace(input.Query)
-		if query == "" {
-			return nil, mcp.NewError(mcp.ErrInvalidParams, "query is required")
-		}
+func SearchContext(ctx context.Context, store *db.DB, settings Settings, input SearchContextParams) (*SearchContextResult, *mcp.RPCError) {
+	query := strings.TrimSpace(input.Query)
+	if query == "" {
+		return nil, mcp.InvalidField("query", "required", "query is required")
+	}
 
-		topK := defaultTopK
-		if input.TopK != nil {
-			topK = *input.TopK
-		}
-		topK = common.ClampInt(topK, 1, maxTopK)
+	topK := settings.DefaultTopK
+	if input.TopK != nil {
+		topK = *input.TopK
+	}
+	topK = common.ClampInt(topK, 1, settings.MaxTopK)
 
-		minImportance := defaultMinImportance
-		if input.MinImportance != nil {
-			minImportance = *input.MinImportance
-		}
-		if minImportance < 1 {
-			minImportance = defaultMinImportance
-		}
+	minImportance := defaultMinImportance
+	if input.MinImportance != nil {
+		minImportance = *input.MinImportance
+	}
+	if minImportance < 1 {
+		minImportance = defaultMinImportance
+	}
 
-		results, err := store.SearchContext(ctx, query, topK, input.ThreadID, minImportance)
-		if err != nil {
-			return nil, mcp.NewError(mcp.ErrInternal, err.Error())
-		}
+	namespace := input.Namespace
+	if namespace == nil {
+		namespace = settings.Namespace
+	}
 
-		return SearchContextResult{Items: results}, nil
+	results, err := store.SearchContext(ctx, query, db.SearchFilter{
+		TopK:          topK,
+		Namespace:     namespace,
+		ThreadID:      input.ThreadID,
+		MinImportance: minImportance,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrInvalidQuery) {
+			return nil, mcp.InvalidField("query", "fts5_syntax", "query is not valid FTS5 syntax; quote phrases and avoid stray punctuation")
+		}
+		return nil, mcp.StorageError("search context", err)
 	}
+
+	return &SearchContextResult{Items: results}, nil
 }
@@ -0,0 +1,20 @@
+package tools
+
+// Settings holds the configurable defaults and limits of the tools.
+type Settings struct {
+	DefaultTopK       int
+	MaxTopK           int
+	DefaultImportance int
+	// Namespace applies to requests that do not name one.
+	Namespace *string
+	// Tags are added to the tags of every saved item.
+	Tags []string
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		DefaultTopK:       defaultTopK,
+		MaxTopK:           maxTopK,
+		DefaultImportance: defaultImportance,
+	}
+}
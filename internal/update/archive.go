@@ -0,0 +1,93 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"runtime"
+)
+
+// binaryName is the name of the executable inside release archives.
+func binaryName() string {
+	if runtime.GOOS == "windows" {
+		return "vcontext.exe"
+	}
+	return "vcontext"
+}
+
+// extractBinary copies the vcontext executable out of the archive at src,
+// wherever it sits in the archive tree, into dst.
+func extractBinary(src *os.File, kind string, dst io.Writer) error {
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek archive: %w", err)
+	}
+
+	var err error
+	switch kind {
+	case archiveTarGz:
+		err = extractTarGz(src, dst)
+	case archiveZip:
+		err = extractZip(src, dst)
+	default:
+		err = fmt.Errorf("unknown archive format %q", kind)
+	}
+	if err != nil {
+		return fmt.Errorf("extract %s: %w", binaryName(), err)
+	}
+	return nil
+}
+
+var errBinaryMissing = errors.New("archive does not contain the binary")
+
+func extractTarGz(src io.Reader, dst io.Writer) error {
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	archive := tar.NewReader(gz)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return errBinaryMissing
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeReg && path.Base(header.Name) == binaryName() {
+			_, err := io.Copy(dst, archive)
+			return err
+		}
+	}
+}
+
+func extractZip(src *os.File, dst io.Writer) error {
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	archive, err := zip.NewReader(src, info.Size())
+	if err != nil {
+		return err
+	}
+
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || path.Base(file.Name) != binaryName() {
+			continue
+		}
+		body, err := file.Open()
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		_, err = io.Copy(dst, body)
+		return err
+	}
+	return errBinaryMissing
+}
@@ -0,0 +1,169 @@
+package update
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// Archive formats the binary may be published in.
+const (
+	archiveNone  = ""
+	archiveTarGz = "tar.gz"
+	archiveZip   = "zip"
+)
+
+var (
+	osAliases = map[string][]string{
+		"darwin":  {"darwin", "macos", "mac"},
+		"windows": {"windows", "win"},
+	}
+	archAliases = map[string][]string{
+		"amd64": {"amd64", "x86_64", "x64"},
+		"arm64": {"arm64", "aarch64"},
+		"386":   {"386", "i386", "x86"},
+	}
+)
+
+func buildAssetName() string {
+	name := fmt.Sprintf("vcontext_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// archiveKind tells the archive format from an asset name.
+func archiveKind(name string) (string, bool) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return archiveTarGz, true
+	case strings.HasSuffix(lower, ".zip"):
+		return archiveZip, true
+	case strings.HasSuffix(lower, ".exe"):
+		return archiveNone, runtime.GOOS == "windows"
+	}
+	// The dots of a version such as vcontext-1.2.3-linux-amd64 do not start
+	// an extension; any other extension marks checksums, signatures,
+	// packages and the like.
+	if ext := filepath.Ext(lower); ext != "" && !strings.ContainsAny(ext, "-_") {
+		return archiveNone, false
+	}
+	return archiveNone, runtime.GOOS != "windows"
+}
+
+// matchesPlatform reports whether an asset is built for goos and goarch.
+// Names such as vcontext_1.2.3_linux_x86_64.tar.gz or
+// vcontext-darwin-arm64.zip match; the os and arch must be whole words.
+func matchesPlatform(name string, goos string, goarch string) bool {
+	lower := strings.ToLower(name)
+	if !strings.HasPrefix(lower, "vcontext") {
+		return false
+	}
+	words := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(lower) + "_"
+	hasWord := func(aliases []string) bool {
+		return slices.ContainsFunc(aliases, func(alias string) bool {
+			return strings.Contains(words, "_"+alias+"_")
+		})
+	}
+	return hasWord(aliasesOf(osAliases, goos)) && hasWord(aliasesOf(archAliases, goarch))
+}
+
+func aliasesOf(aliases map[string][]string, name string) []string {
+	if list, ok := aliases[name]; ok {
+		return list
+	}
+	return []string{name}
+}
+
+// isCombinedChecksums reports whether an asset lists the checksums of every
+// other asset, as checksums.txt or SHA256SUMS.
+func isCombinedChecksums(name string) bool {
+	lower := strings.ToLower(name)
+	return lower == "checksums.txt" || lower == "sha256sums" || lower == "sha256sums.txt" ||
+		strings.HasSuffix(lower, "_checksums.txt") || strings.HasSuffix(lower, "-checksums.txt")
+}
+
+// findAsset picks the asset for this platform, preferring the plain
+// binary named by buildAssetName, and the checksum and signature that
+// cover it: its own .sha256 file, or else a combined checksums file.
+func findAsset(assets []Asset) (asset *Asset, checksum *Asset, signature *Asset) {
+	exact := buildAssetName()
+	for i := range assets {
+		if assets[i].Name == exact {
+			asset = &assets[i]
+			break
+		}
+	}
+	if asset == nil {
+		for i := range assets {
+			if _, ok := archiveKind(assets[i].Name); ok && matchesPlatform(assets[i].Name, runtime.GOOS, runtime.GOARCH) {
+				asset = &assets[i]
+				break
+			}
+		}
+	}
+	if asset == nil {
+		return nil, nil, nil
+	}
+
+	byName := func(name string) *Asset {
+		for i := range assets {
+			if assets[i].Name == name {
+				return &assets[i]
+			}
+		}
+		return nil
+	}
+	checksum = byName(asset.Name + ".sha256")
+	if checksum == nil {
+		for i := range assets {
+			if isCombinedChecksums(assets[i].Name) {
+				checksum = &assets[i]
+				break
+			}
+		}
+	}
+	if checksum != nil {
+		signature = byName(checksum.Name + ".sig")
+	}
+	return asset, checksum, signature
+}
+
+// checksumFor finds the hash of the asset in a checksum file, either a
+// single "<hash>  <name>" line or a combined list.
+func checksumFor(data []byte, assetName string) (string, error) {
+	var lines [][]string
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			lines = append(lines, fields)
+		}
+	}
+	if len(lines) == 0 {
+		return "", errors.New("checksum file empty")
+	}
+
+	// Tools write the name as given on their command line, so a file
+	// hashed as dist/<asset> still counts.
+	listed := func(fields []string) string {
+		return path.Base(strings.TrimPrefix(fields[1], "*"))
+	}
+	for _, fields := range lines {
+		if len(fields) > 1 && listed(fields) == assetName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if len(lines) == 1 {
+		if len(lines[0]) == 1 {
+			return strings.ToLower(lines[0][0]), nil
+		}
+		// A signed checksum of another asset must not vouch for this one.
+		return "", fmt.Errorf("checksum file is for %s, not %s", listed(lines[0]), assetName)
+	}
+	return "", fmt.Errorf("checksum file does not list %s", assetName)
+}
@@ -0,0 +1,197 @@
+package update
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestMatchesPlatform(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		goos   string
+		goarch string
+		want   bool
+	}{
+		{"vcontext_linux_amd64", "linux", "amd64", true},
+		{"vcontext_linux_x86_64.tar.gz", "linux", "amd64", true},
+		{"vcontext-linux-x64.tar.gz", "linux", "amd64", true},
+		{"vcontext_Linux_aarch64.tar.gz", "linux", "arm64", true},
+		{"vcontext_1.2.3_darwin_arm64.tar.gz", "darwin", "arm64", true},
+		{"vcontext-v1.2.3-macos-arm64.zip", "darwin", "arm64", true},
+		{"vcontext_1.2.3_windows_x86_64.zip", "windows", "amd64", true},
+		{"vcontext_linux_i386.tar.gz", "linux", "386", true},
+		{"vcontext_linux_arm64.tar.gz", "linux", "amd64", false},
+		{"vcontext_linux_amd64.tar.gz", "linux", "arm64", false},
+		{"vcontext_darwin_amd64.tar.gz", "linux", "amd64", false},
+		// os and arch must be whole words, not parts of a longer one.
+		{"vcontext_linux_amd64p32.tar.gz", "linux", "amd64", false},
+		{"vcontext_linuxmusl_amd64.tar.gz", "linux", "amd64", false},
+		{"other_linux_amd64.tar.gz", "linux", "amd64", false},
+	} {
+		if got := matchesPlatform(tt.name, tt.goos, tt.goarch); got != tt.want {
+			t.Errorf("matchesPlatform(%q, %s, %s) = %v, want %v", tt.name, tt.goos, tt.goarch, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveKind(t *testing.T) {
+	windows := runtime.GOOS == "windows"
+	for _, tt := range []struct {
+		name   string
+		kind   string
+		binary bool
+	}{
+		{"vcontext_linux_amd64.tar.gz", archiveTarGz, true},
+		{"vcontext_linux_amd64.TGZ", archiveTarGz, true},
+		{"vcontext_windows_amd64.zip", archiveZip, true},
+		{"vcontext_windows_amd64.exe", archiveNone, windows},
+		{"vcontext_linux_amd64", archiveNone, !windows},
+		{"vcontext-1.2.3-linux-amd64", archiveNone, !windows},
+		{"vcontext_1.2.3_linux_amd64", archiveNone, !windows},
+		{"vcontext_linux_amd64.sha256", archiveNone, false},
+		{"vcontext_linux_amd64.tar.gz.sig", archiveNone, false},
+		{"vcontext_linux_amd64.deb", archiveNone, false},
+		{"checksums.txt", archiveNone, false},
+	} {
+		kind, binary := archiveKind(tt.name)
+		if kind != tt.kind || binary != tt.binary {
+			t.Errorf("archiveKind(%q) = %q, %v, want %q, %v", tt.name, kind, binary, tt.kind, tt.binary)
+		}
+	}
+}
+
+func TestChecksumFor(t *testing.T) {
+	hash := strings.Repeat("ab", 32)
+	other := strings.Repeat("cd", 32)
+	combined := other + "  vcontext_darwin_arm64.tar.gz\n" +
+		strings.ToUpper(hash) + " *dist/vcontext_linux_amd64.tar.gz\n" +
+		other + "  checksums.txt.sig\n"
+
+	for _, tt := range []struct {
+		name     string
+		data     string
+		asset    string
+		want     string
+		wantText string
+	}{
+		{"own line", hash + "  vcontext_linux_amd64.tar.gz\n", "vcontext_linux_amd64.tar.gz", hash, ""},
+		{"bare hash", hash + "\n", "vcontext_linux_amd64.tar.gz", hash, ""},
+		{"combined", combined, "vcontext_linux_amd64.tar.gz", hash, ""},
+		{"combined other asset", combined, "vcontext_darwin_arm64.tar.gz", other, ""},
+		{"not listed", combined, "vcontext_windows_amd64.zip", "", "does not list vcontext_windows_amd64.zip"},
+		{"single line of another asset", hash + "  vcontext_darwin_arm64.tar.gz\n", "vcontext_linux_amd64.tar.gz", "", "checksum file is for vcontext_darwin_arm64.tar.gz"},
+		{"empty", "\n\n", "vcontext_linux_amd64.tar.gz", "", "checksum file empty"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := checksumFor([]byte(tt.data), tt.asset)
+			if tt.wantText != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantText) {
+					t.Fatalf("checksumFor = %q, %v, want an error mentioning %q", got, err, tt.wantText)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("checksumFor = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractBinary(t *testing.T) {
+	binary := "new vcontext binary"
+	files := map[string]string{
+		"vcontext-2.0.0/README.md":       "readme",
+		"vcontext-2.0.0/" + binaryName(): binary,
+	}
+
+	for _, tt := range []struct {
+		name    string
+		kind    string
+		archive []byte
+		want    string
+		wantErr error
+	}{
+		{"tar.gz", archiveTarGz, tarGz(t, files), binary, nil},
+		{"zip", archiveZip, zipArchive(t, files), binary, nil},
+		{"tar.gz without the binary", archiveTarGz, tarGz(t, map[string]string{"README.md": "readme"}), "", errBinaryMissing},
+		{"zip without the binary", archiveZip, zipArchive(t, map[string]string{"README.md": "readme"}), "", errBinaryMissing},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "archive")
+			if err := os.WriteFile(path, tt.archive, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			src, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer src.Close()
+			// extractBinary rewinds the file the download left at its end.
+			if _, err := src.Seek(0, io.SeekEnd); err != nil {
+				t.Fatal(err)
+			}
+
+			var dst bytes.Buffer
+			err = extractBinary(src, tt.kind, &dst)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("extractBinary: %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || dst.String() != tt.want {
+				t.Fatalf("extractBinary = %q, %v, want %q", dst.String(), err, tt.want)
+			}
+		})
+	}
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	archive := tar.NewWriter(gz)
+	for name, body := range files {
+		if err := archive.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := archive.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
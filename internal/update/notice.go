@@ -0,0 +1,109 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// NoticeInterval is how often the background update check runs.
+const NoticeInterval = 24 * time.Hour
+
+// Notice is the cached outcome of the last background update check.
+type Notice struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Channel   string    `json:"channel"`
+	Latest    string    `json:"latest,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Available returns the newer release the notice knows of, or "". Builds
+// that are not versions, such as dev builds, are never told to update.
+func (n Notice) Available(current string) string {
+	if !IsRelease(current) || n.Latest == "" {
+		return ""
+	}
+	if CompareVersions(n.Latest, current) > 0 {
+		return n.Latest
+	}
+	return ""
+}
+
+// Stale reports whether the notice is due for another check on channel.
+func (n Notice) Stale(channel string) bool {
+	if channel == "" {
+		channel = ChannelStable
+	}
+	return n.Channel != channel || time.Since(n.CheckedAt) >= NoticeInterval
+}
+
+// IsRelease reports whether a build version is a release that update
+// checks apply to; dev and other unversioned builds are not.
+func IsRelease(current string) bool {
+	_, ok := parseVersion(current)
+	return ok
+}
+
+// LoadNotice reads the cached notice; without a cache it returns the zero
+// Notice.
+func LoadNotice(path string) (Notice, error) {
+	var notice Notice
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return notice, nil
+	}
+	if err != nil {
+		return notice, fmt.Errorf("read update notice: %w", err)
+	}
+	if err := json.Unmarshal(data, &notice); err != nil {
+		return Notice{}, fmt.Errorf("read update notice %s: %w", path, err)
+	}
+	return notice, nil
+}
+
+// RefreshNotice checks for a newer release unless the cached notice was
+// checked within NoticeInterval for the same channel. Failed checks are
+// cached as well, so an offline machine does not retry on every start.
+func RefreshNotice(ctx context.Context, path string, opts Options) (Notice, error) {
+	channel := opts.Channel
+	if channel == "" {
+		channel = ChannelStable
+	}
+	cached, err := LoadNotice(path)
+	if err == nil && !cached.Stale(channel) {
+		return cached, nil
+	}
+
+	notice := Notice{CheckedAt: time.Now().UTC(), Channel: channel}
+	opts.Version = ""
+	if release, err := Check(ctx, opts); err != nil {
+		notice.Error = err.Error()
+	} else {
+		notice.Latest = release.Tag
+	}
+
+	return notice, saveNotice(path, notice)
+}
+
+func saveNotice(path string, notice Notice) error {
+	data, err := json.MarshalIndent(notice, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("save update notice: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("save update notice: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("save update notice: %w", err)
+	}
+	return nil
+}
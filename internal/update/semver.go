@@ -0,0 +1,111 @@
+package update
+
+import (
+	"strconv"
+	"strings"
+)
+
+// version is a parsed semantic version; build metadata is dropped.
+type version struct {
+	major, minor, patch int
+	pre                 []string
+}
+
+// parseVersion accepts "1.2.3", "v1.2.3" and pre-releases such as
+// "v1.3.0-rc.1". Missing minor or patch numbers count as zero.
+func parseVersion(text string) (version, bool) {
+	text = strings.TrimPrefix(strings.TrimSpace(text), "v")
+	text, _, _ = strings.Cut(text, "+")
+	core, pre, hasPre := strings.Cut(text, "-")
+
+	parts := strings.Split(core, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return version{}, false
+	}
+	numbers := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version{}, false
+		}
+		numbers[i] = n
+	}
+
+	v := version{major: numbers[0], minor: numbers[1], patch: numbers[2]}
+	if hasPre {
+		if pre == "" {
+			return version{}, false
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+	return v, true
+}
+
+func (v version) prerelease() bool {
+	return len(v.pre) > 0
+}
+
+// compare orders versions by semver precedence.
+func (v version) compare(other version) int {
+	for _, pair := range [][2]int{{v.major, other.major}, {v.minor, other.minor}, {v.patch, other.patch}} {
+		if pair[0] != pair[1] {
+			return compareInts(pair[0], pair[1])
+		}
+	}
+
+	// A release ranks above its pre-releases.
+	switch {
+	case !v.prerelease() && !other.prerelease():
+		return 0
+	case !v.prerelease():
+		return 1
+	case !other.prerelease():
+		return -1
+	}
+
+	for i := 0; i < len(v.pre) && i < len(other.pre); i++ {
+		a, b := v.pre[i], other.pre[i]
+		if a == b {
+			continue
+		}
+		aNum, aErr := strconv.Atoi(a)
+		bNum, bErr := strconv.Atoi(b)
+		switch {
+		case aErr == nil && bErr == nil:
+			return compareInts(aNum, bNum)
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			return strings.Compare(a, b)
+		}
+	}
+	return compareInts(len(v.pre), len(other.pre))
+}
+
+func compareInts(a int, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// CompareVersions orders two version strings by semver precedence. A
+// string that is not a version, such as "dev", sorts below every version.
+func CompareVersions(a string, b string) int {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	switch {
+	case okA && okB:
+		return va.compare(vb)
+	case okA:
+		return 1
+	case okB:
+		return -1
+	}
+	return 0
+}
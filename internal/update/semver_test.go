@@ -0,0 +1,68 @@
+package update
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.3", "1.2.3", 0},
+		{"v1.2.3", "v1.2.3+build.7", 0},
+		{"v1.2", "v1.2.0", 0},
+		{"v1.10.0", "v1.9.0", 1},
+		{"v2.0.0", "v10.0.0", -1},
+		{"v1.3.0-rc.2", "v1.3.0-rc.10", -1},
+		{"v1.3.0-rc.10", "v1.3.0", -1},
+		{"v1.3.0-rc.1", "v1.2.9", 1},
+		{"v1.3.0-alpha", "v1.3.0-alpha.1", -1},
+		{"v1.3.0-alpha.1", "v1.3.0-beta", -1},
+		{"v1.3.0-1", "v1.3.0-alpha", -1},
+		{"dev", "v0.0.1", -1},
+		{"v0.0.1", "dev", 1},
+		{"dev", "", 0},
+		{"v1.2.3.4", "v0.0.1", -1},
+		{"v1.2.3-", "v0.0.1", -1},
+	} {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestNewestRelease(t *testing.T) {
+	releases := []ReleaseInfo{
+		{Tag: "v1.2.0"},
+		{Tag: "v1.10.0-rc.2"},
+		{Tag: "v1.10.0-rc.10"},
+		{Tag: "v1.9.0"},
+		{Tag: "v1.9.5", Prerelease: true},
+		{Tag: "v2.0.0", Draft: true},
+		{Tag: "nightly"},
+	}
+	for _, tt := range []struct {
+		name     string
+		releases []ReleaseInfo
+		channel  string
+		want     string
+	}{
+		{"stable skips pre-releases", releases, ChannelStable, "v1.9.0"},
+		{"prerelease", releases, ChannelPrerelease, "v1.10.0-rc.10"},
+		{"release above its candidates", append([]ReleaseInfo{{Tag: "v1.10.0"}}, releases...), ChannelPrerelease, "v1.10.0"},
+		{"only pre-releases", []ReleaseInfo{{Tag: "v1.0.0-rc.1"}}, ChannelStable, ""},
+		{"none", nil, ChannelStable, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if rel := newestRelease(tt.releases, tt.channel); rel != nil {
+				got = rel.Tag
+			}
+			if got != tt.want {
+				t.Fatalf("newestRelease = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package update
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	SourceGitHub   = "github"
+	SourceManifest = "manifest"
+	SourceDir      = "dir"
+)
+
+// responseHeaderTimeout bounds the wait for the server to answer a request.
+const responseHeaderTimeout = 60 * time.Second
+
+// DefaultGitHubURL is the API of github.com; GitHub Enterprise serves it
+// under https://<host>/api/v3.
+const DefaultGitHubURL = "https://api.github.com"
+
+// ErrReleaseNotFound means the source has no release with the requested tag.
+var ErrReleaseNotFound = errors.New("release not found")
+
+// ReleaseSource is where SelfUpdate finds releases and downloads their
+// assets.
+type ReleaseSource interface {
+	// Releases lists the published releases in any order.
+	Releases(ctx context.Context) ([]ReleaseInfo, error)
+	// Release returns the release with the tag, or ErrReleaseNotFound.
+	Release(ctx context.Context, tag string) (*ReleaseInfo, error)
+	// Open reads an asset of one of the releases.
+	Open(ctx context.Context, asset Asset) (io.ReadCloser, error)
+}
+
+type ReleaseInfo struct {
+	Tag        string  `json:"tag"`
+	Draft      bool    `json:"draft,omitempty"`
+	Prerelease bool    `json:"prerelease,omitempty"`
+	Assets     []Asset `json:"assets"`
+}
+
+// Asset is a downloadable file of a release. URL is only meaningful to the
+// source that listed it.
+type Asset struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// NewSource returns the source of the given kind. location is the API base
+// URL for SourceGitHub (empty means github.com), the manifest URL for
+// SourceManifest and the directory for SourceDir. repo is only used by
+// SourceGitHub.
+//
+// GITHUB_TOKEN or GH_TOKEN is sent to api.github.com, and to another
+// GitHub location only when trusted says the user chose it.
+func NewSource(kind string, location string, repo string, trusted bool) (ReleaseSource, error) {
+	location = strings.TrimSpace(location)
+	switch kind {
+	case "", SourceGitHub:
+		repo = strings.TrimSpace(repo)
+		if repo == "" {
+			return nil, errors.New("repo is required")
+		}
+		if location == "" {
+			location = DefaultGitHubURL
+		}
+		base, err := url.Parse(location)
+		if err != nil || base.Host == "" {
+			return nil, fmt.Errorf("invalid GitHub API URL %q", location)
+		}
+		return &gitHubSource{
+			baseURL:   strings.TrimRight(location, "/"),
+			repo:      repo,
+			sendToken: trusted || base.Scheme == "https" && base.Host == "api.github.com",
+		}, nil
+	case SourceManifest:
+		if location == "" {
+			return nil, errors.New("manifest source needs a URL")
+		}
+		return &manifestSource{url: location}, nil
+	case SourceDir:
+		if location == "" {
+			return nil, errors.New("dir source needs a directory")
+		}
+		dir, err := filepath.Abs(location)
+		if err != nil {
+			return nil, err
+		}
+		return &dirSource{dir: dir}, nil
+	}
+	return nil, fmt.Errorf("unknown release source %q (want %s, %s or %s)", kind, SourceGitHub, SourceManifest, SourceDir)
+}
+
+// gitHubSource reads the releases API of github.com or GitHub Enterprise.
+type gitHubSource struct {
+	baseURL   string
+	repo      string
+	sendToken bool
+}
+
+type gitHubRelease struct {
+	TagName    string `json:"tag_name"`
+	Draft      bool   `json:"draft"`
+	Prerelease bool   `json:"prerelease"`
+	Assets     []struct {
+		Name        string `json:"name"`
+		APIURL      string `json:"url"`
+		DownloadURL string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+// info keeps the API URL of each asset: unlike the download URL it also
+// serves assets of private repositories, given a token.
+func (r gitHubRelease) info() ReleaseInfo {
+	info := ReleaseInfo{Tag: r.TagName, Draft: r.Draft, Prerelease: r.Prerelease}
+	for _, asset := range r.Assets {
+		url := asset.APIURL
+		if url == "" {
+			url = asset.DownloadURL
+		}
+		info.Assets = append(info.Assets, Asset{Name: asset.Name, URL: url})
+	}
+	return info
+}
+
+func (s *gitHubSource) Releases(ctx context.Context) ([]ReleaseInfo, error) {
+	var releases []gitHubRelease
+	if err := s.get(ctx, fmt.Sprintf("%s/repos/%s/releases?per_page=100", s.baseURL, s.repo), &releases); err != nil {
+		return nil, fmt.Errorf("fetch releases: %w", err)
+	}
+	infos := make([]ReleaseInfo, 0, len(releases))
+	for _, rel := range releases {
+		infos = append(infos, rel.info())
+	}
+	return infos, nil
+}
+
+// Release accepts the tag with or without its "v" prefix.
+func (s *gitHubSource) Release(ctx context.Context, tag string) (*ReleaseInfo, error) {
+	candidates := []string{tag}
+	if !strings.HasPrefix(tag, "v") {
+		candidates = append(candidates, "v"+tag)
+	}
+
+	for _, candidate := range candidates {
+		var rel gitHubRelease
+		err := s.get(ctx, fmt.Sprintf("%s/repos/%s/releases/tags/%s", s.baseURL, s.repo, url.PathEscape(candidate)), &rel)
+		if err == nil {
+			info := rel.info()
+			return &info, nil
+		}
+		var status *statusError
+		if !errors.As(err, &status) || status.code != http.StatusNotFound {
+			return nil, fmt.Errorf("fetch release %s: %w", tag, err)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrReleaseNotFound, tag)
+}
+
+// Open downloads through the API, which redirects to the file; the token
+// is not forwarded to the redirect's host.
+func (s *gitHubSource) Open(ctx context.Context, asset Asset) (io.ReadCloser, error) {
+	return openURL(ctx, asset.URL, s.header("application/octet-stream"))
+}
+
+func (s *gitHubSource) get(ctx context.Context, url string, out any) error {
+	body, err := openURL(ctx, url, s.header("application/vnd.github+json"))
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
+
+func (s *gitHubSource) header(accept string) http.Header {
+	header := http.Header{}
+	header.Set("Accept", accept)
+	if s.sendToken {
+		if token := strings.TrimSpace(os.Getenv("GITHUB_TOKEN")); token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		} else if token := strings.TrimSpace(os.Getenv("GH_TOKEN")); token != "" {
+			header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	return header
+}
+
+// manifestSource reads a JSON document of the form
+// {"releases": [{"tag": "v1.2.3", "assets": [{"name": "...", "url": "..."}]}]}.
+// Asset URLs may be relative to the manifest.
+type manifestSource struct {
+	url string
+}
+
+func (s *manifestSource) Releases(ctx context.Context) ([]ReleaseInfo, error) {
+	base, err := url.Parse(s.url)
+	if err != nil {
+		return nil, fmt.Errorf("manifest url: %w", err)
+	}
+	body, err := openURL(ctx, s.url, http.Header{"Accept": {"application/json"}})
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest: %w", err)
+	}
+	defer body.Close()
+
+	var manifest struct {
+		Releases []ReleaseInfo `json:"releases"`
+	}
+	if err := json.NewDecoder(body).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("decode manifest: %w", err)
+	}
+	for _, rel := range manifest.Releases {
+		for i, asset := range rel.Assets {
+			ref, err := url.Parse(asset.URL)
+			if err != nil {
+				return nil, fmt.Errorf("manifest release %s: asset %s: %w", rel.Tag, asset.Name, err)
+			}
+			rel.Assets[i].URL = base.ResolveReference(ref).String()
+		}
+	}
+	return manifest.Releases, nil
+}
+
+func (s *manifestSource) Release(ctx context.Context, tag string) (*ReleaseInfo, error) {
+	return findRelease(ctx, s, tag)
+}
+
+func (s *manifestSource) Open(ctx context.Context, asset Asset) (io.ReadCloser, error) {
+	return openURL(ctx, asset.URL, nil)
+}
+
+// dirSource reads a directory with one subdirectory of assets per release,
+// named after its tag.
+type dirSource struct {
+	dir string
+}
+
+func (s *dirSource) Releases(ctx context.Context) ([]ReleaseInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read release dir: %w", err)
+	}
+
+	var releases []ReleaseInfo
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read release dir: %w", err)
+		}
+		rel := ReleaseInfo{Tag: entry.Name()}
+		for _, file := range files {
+			if file.Type().IsRegular() {
+				rel.Assets = append(rel.Assets, Asset{Name: file.Name(), URL: filepath.Join(s.dir, entry.Name(), file.Name())})
+			}
+		}
+		releases = append(releases, rel)
+	}
+	return releases, nil
+}
+
+func (s *dirSource) Release(ctx context.Context, tag string) (*ReleaseInfo, error) {
+	return findRelease(ctx, s, tag)
+}
+
+func (s *dirSource) Open(ctx context.Context, asset Asset) (io.ReadCloser, error) {
+	return os.Open(asset.URL)
+}
+
+// findRelease looks up a tag in the listing of sources without a direct
+// lookup; like GitHub, the "v" prefix is optional.
+func findRelease(ctx context.Context, source ReleaseSource, tag string) (*ReleaseInfo, error) {
+	releases, err := source.Releases(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range releases {
+		if releases[i].Tag == tag || releases[i].Tag == "v"+tag {
+			return &releases[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrReleaseNotFound, tag)
+}
+
+// httpClient bounds the wait for a response but not the download of its
+// body, which may be large and slow.
+var httpClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = responseHeaderTimeout
+	return &http.Client{Transport: transport}
+}()
+
+// statusError is an unsuccessful HTTP response.
+type statusError struct {
+	code   int
+	status string
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.status, e.body)
+}
+
+// openURL starts a GET request and returns the body of a successful
+// response.
+func openURL(ctx context.Context, url string, header http.Header) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("User-Agent", "vcontext")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &statusError{code: resp.StatusCode, status: resp.Status, body: strings.TrimSpace(string(body))}
+	}
+	return resp.Body, nil
+}
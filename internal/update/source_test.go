@@ -0,0 +1,126 @@
+package update
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGitHubSourceToken(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "secret")
+
+	var got string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, tt := range []struct {
+		trusted bool
+		want    string
+	}{
+		{trusted: false, want: ""},
+		{trusted: true, want: "Bearer secret"},
+	} {
+		source, err := NewSource(SourceGitHub, server.URL, "org/vcontext", tt.trusted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := source.Releases(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("trusted=%v: Authorization = %q, want %q", tt.trusted, got, tt.want)
+		}
+	}
+}
+
+func TestGitHubSourceRelease(t *testing.T) {
+	status := http.StatusNotFound
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/repos/org/vcontext/releases/tags/v1.2.0" {
+			_, _ = w.Write([]byte(`{"tag_name": "v1.2.0"}`))
+			return
+		}
+		http.Error(w, "nope", status)
+	}))
+	t.Cleanup(server.Close)
+
+	source, err := NewSource(SourceGitHub, server.URL, "org/vcontext", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rel, err := source.Release(context.Background(), "1.2.0")
+	if err != nil || rel.Tag != "v1.2.0" {
+		t.Fatalf("release 1.2.0 = %+v, %v", rel, err)
+	}
+
+	paths = nil
+	if _, err := source.Release(context.Background(), "1.3.0"); !errors.Is(err, ErrReleaseNotFound) {
+		t.Fatalf("missing release = %v, want %v", err, ErrReleaseNotFound)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("looked up %v, want both spellings of the tag", paths)
+	}
+
+	status = http.StatusInternalServerError
+	paths = nil
+	_, err = source.Release(context.Background(), "1.3.0")
+	if err == nil || errors.Is(err, ErrReleaseNotFound) {
+		t.Fatalf("release on a failing server = %v, want a fetch error", err)
+	}
+	if len(paths) != 1 {
+		t.Fatalf("looked up %v after a server error, want one request", paths)
+	}
+}
+
+func TestGitHubSourceOpen(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "secret")
+
+	var storageAuth string
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		storageAuth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte("binary"))
+	}))
+	t.Cleanup(storage.Close)
+
+	var apiAccept, apiAuth string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/vcontext/releases/assets/1" {
+			http.NotFound(w, r)
+			return
+		}
+		apiAccept, apiAuth = r.Header.Get("Accept"), r.Header.Get("Authorization")
+		// Like GitHub, redirect to a storage host of another name.
+		http.Redirect(w, r, strings.Replace(storage.URL, "127.0.0.1", "localhost", 1)+"/signed", http.StatusFound)
+	}))
+	t.Cleanup(api.Close)
+
+	source, err := NewSource(SourceGitHub, api.URL, "org/vcontext", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := source.Open(context.Background(), Asset{Name: "vcontext", URL: api.URL + "/repos/org/vcontext/releases/assets/1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	_ = body.Close()
+
+	if string(data) != "binary" {
+		t.Fatalf("asset = %q", data)
+	}
+	if apiAccept != "application/octet-stream" || apiAuth != "Bearer secret" {
+		t.Fatalf("API request: Accept %q, Authorization %q", apiAccept, apiAuth)
+	}
+	if storageAuth != "" {
+		t.Fatalf("token forwarded to the redirect: %q", storageAuth)
+	}
+}
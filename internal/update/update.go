@@ -3,63 +3,139 @@ package update
 import (
 	"context"
 	"crypto/sha256"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
 )
 
 var ErrAlreadyLatest = errors.New("already latest version")
 
-type releaseInfo struct {
-	TagName string         `json:"tag_name"`
-	Assets  []releaseAsset `json:"assets"`
+// ErrNoBackup means there is no binary from before the last update.
+var ErrNoBackup = errors.New("no previous binary to roll back to")
+
+const (
+	ChannelStable     = "stable"
+	ChannelPrerelease = "prerelease"
+)
+
+// backupSuffix names the copy of the binary kept by SelfUpdate.
+const backupSuffix = ".previous"
+
+type Options struct {
+	// Source is where releases are looked up; nil means the GitHub
+	// repository Repo.
+	Source         ReleaseSource
+	Repo           string
+	CurrentVersion string
+	// Channel is ChannelStable, the default, or ChannelPrerelease which
+	// also considers pre-releases.
+	Channel string
+	// Version pins the release to install, even an older one.
+	Version string
+	// Insecure installs releases that are not signed or that this binary
+	// cannot verify. A checksum or signature that does not match is still
+	// refused.
+	Insecure bool
 }
 
-type releaseAsset struct {
-	Name string `json:"name"`
-	URL  string `json:"browser_download_url"`
+// Release is the release selected for Options.
+type Release struct {
+	Tag        string `json:"tag"`
+	Prerelease bool   `json:"prerelease"`
+	// Newer reports whether Tag is newer than Options.CurrentVersion.
+	Newer bool `json:"newer"`
+
+	assets []Asset
 }
 
-func SelfUpdate(ctx context.Context, repo string, currentVersion string) (string, error) {
-	repo = strings.TrimSpace(repo)
-	if repo == "" {
-		return "", errors.New("repo is required")
+// Check selects the release SelfUpdate would install without downloading
+// it: the pinned Version, or the newest release of the channel.
+func Check(ctx context.Context, opts Options) (*Release, error) {
+	source, err := opts.source()
+	if err != nil {
+		return nil, err
+	}
+	channel := opts.Channel
+	if channel == "" {
+		channel = ChannelStable
+	}
+	if channel != ChannelStable && channel != ChannelPrerelease {
+		return nil, fmt.Errorf("unknown channel %q (want %s or %s)", channel, ChannelStable, ChannelPrerelease)
 	}
 
-	rel, err := fetchLatestRelease(ctx, repo)
-	if err != nil {
-		return "", err
+	var rel *ReleaseInfo
+	if pinned := strings.TrimSpace(opts.Version); pinned != "" {
+		found, err := source.Release(ctx, pinned)
+		if err != nil {
+			return nil, err
+		}
+		rel = found
+	} else {
+		releases, err := source.Releases(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if rel = newestRelease(releases, channel); rel == nil {
+			return nil, fmt.Errorf("no %s release found", channel)
+		}
 	}
 
-	tag := strings.TrimSpace(rel.TagName)
+	tag := strings.TrimSpace(rel.Tag)
 	if tag == "" {
-		return "", errors.New("latest release has no tag")
+		return nil, errors.New("release has no tag")
+	}
+	parsed, _ := parseVersion(tag)
+	return &Release{
+		Tag:        tag,
+		Prerelease: rel.Prerelease || parsed.prerelease(),
+		Newer:      CompareVersions(tag, opts.CurrentVersion) > 0,
+		assets:     rel.Assets,
+	}, nil
+}
+
+// SelfUpdate installs the release selected by Check over the running
+// binary and keeps the replaced binary for Rollback.
+func SelfUpdate(ctx context.Context, opts Options) (string, error) {
+	exePath, err := executablePath()
+	if err != nil {
+		return "", err
 	}
+	return install(ctx, opts, exePath)
+}
 
-	if isSameVersion(tag, currentVersion) {
-		return tag, ErrAlreadyLatest
+// install is SelfUpdate for the binary at exePath.
+func install(ctx context.Context, opts Options, exePath string) (string, error) {
+	source, err := opts.source()
+	if err != nil {
+		return "", err
+	}
+	opts.Source = source
+	rel, err := Check(ctx, opts)
+	if err != nil {
+		return "", err
 	}
 
-	assetName := buildAssetName()
-	assetURL, checksumURL := findAsset(rel.Assets, assetName)
-	if assetURL == "" {
-		return "", fmt.Errorf("asset not found for %s", assetName)
+	current, isVersion := parseVersion(opts.CurrentVersion)
+	if opts.Version == "" && !rel.Newer {
+		return rel.Tag, ErrAlreadyLatest
+	}
+	if pinned, ok := parseVersion(rel.Tag); ok && isVersion && pinned.compare(current) == 0 {
+		return rel.Tag, ErrAlreadyLatest
 	}
 
-	exePath, err := os.Executable()
-	if err != nil {
-		return "", fmt.Errorf("resolve executable path: %w", err)
+	asset, checksum, signature := findAsset(rel.assets)
+	if asset == nil {
+		return "", fmt.Errorf("no %s asset for %s/%s in %s", binaryName(), runtime.GOOS, runtime.GOARCH, rel.Tag)
 	}
-	exePath, err = filepath.Abs(exePath)
+	kind, _ := archiveKind(asset.Name)
+
+	expectedHash, err := verifiedChecksum(ctx, source, asset.Name, checksum, signature, opts.Insecure)
 	if err != nil {
-		return "", fmt.Errorf("resolve executable path: %w", err)
+		return "", err
 	}
 
 	targetDir := filepath.Dir(exePath)
@@ -71,122 +147,178 @@ func SelfUpdate(ctx context.Context, repo string, currentVersion string) (string
 	defer func() {
 		_ = os.Remove(tmpPath)
 	}()
+	installPath := tmpPath
 
-	if err := downloadToFile(ctx, assetURL, tmpFile, checksumURL); err != nil {
+	if err := downloadToFile(ctx, source, *asset, tmpFile, expectedHash); err != nil {
 		_ = tmpFile.Close()
 		return "", err
 	}
+	if kind != archiveNone {
+		// The checksum covers the archive; the binary is taken out of it
+		// only after the archive checked out.
+		archive := tmpFile
+		if tmpFile, err = os.CreateTemp(targetDir, "vcontext-update-*"); err != nil {
+			_ = archive.Close()
+			return "", fmt.Errorf("create temp file: %w", err)
+		}
+		binaryPath := tmpFile.Name()
+		defer func() {
+			_ = os.Remove(binaryPath)
+		}()
+		err := extractBinary(archive, kind, tmpFile)
+		_ = archive.Close()
+		if err != nil {
+			_ = tmpFile.Close()
+			return "", err
+		}
+		installPath = binaryPath
+	}
 	if err := tmpFile.Close(); err != nil {
 		return "", fmt.Errorf("close temp file: %w", err)
 	}
 
 	if runtime.GOOS != "windows" {
-		if err := os.Chmod(tmpPath, 0o755); err != nil {
+		if err := os.Chmod(installPath, 0o755); err != nil {
 			return "", fmt.Errorf("chmod temp file: %w", err)
 		}
 	}
 
-	if err := replaceBinary(tmpPath, exePath); err != nil {
+	if err := replaceBinary(installPath, exePath); err != nil {
 		return "", err
 	}
 
-	return tag, nil
+	return rel.Tag, nil
 }
 
-func fetchLatestRelease(ctx context.Context, repo string) (*releaseInfo, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo)
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+// Rollback swaps the binary with the one kept by the last update, so a
+// second rollback undoes the first.
+func Rollback() error {
+	exePath, err := executablePath()
 	if err != nil {
-		return nil, err
+		return err
 	}
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("User-Agent", "vcontext")
+	return rollback(exePath)
+}
 
-	if token := strings.TrimSpace(os.Getenv("GITHUB_TOKEN")); token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	} else if token := strings.TrimSpace(os.Getenv("GH_TOKEN")); token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
+// rollback is Rollback for the binary at exePath.
+func rollback(exePath string) error {
+	backup := exePath + backupSuffix
+	if _, err := os.Stat(backup); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return ErrNoBackup
+		}
+		return fmt.Errorf("rollback: %w", err)
 	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("fetch latest release: %w", err)
+	swap := exePath + ".rollback"
+	_ = os.Remove(swap)
+	if err := os.Rename(exePath, swap); err != nil {
+		return fmt.Errorf("rollback: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return nil, fmt.Errorf("fetch latest release: %s", strings.TrimSpace(string(body)))
+	if err := os.Rename(backup, exePath); err != nil {
+		_ = os.Rename(swap, exePath)
+		return fmt.Errorf("rollback: %w", err)
 	}
-
-	var rel releaseInfo
-	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
-		return nil, fmt.Errorf("decode release: %w", err)
+	if err := os.Rename(swap, backup); err != nil {
+		return fmt.Errorf("rollback: keep replaced binary: %w", err)
 	}
+	return nil
+}
 
-	return &rel, nil
+func executablePath() (string, error) {
+	exePath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("resolve executable path: %w", err)
+	}
+	exePath, err = filepath.EvalSymlinks(exePath)
+	if err != nil {
+		return "", fmt.Errorf("resolve executable path: %w", err)
+	}
+	exePath, err = filepath.Abs(exePath)
+	if err != nil {
+		return "", fmt.Errorf("resolve executable path: %w", err)
+	}
+	return exePath, nil
 }
 
-func buildAssetName() string {
-	name := fmt.Sprintf("vcontext_%s_%s", runtime.GOOS, runtime.GOARCH)
-	if runtime.GOOS == "windows" {
-		name += ".exe"
+func (opts Options) source() (ReleaseSource, error) {
+	if opts.Source != nil {
+		return opts.Source, nil
 	}
-	return name
+	return NewSource(SourceGitHub, "", opts.Repo, false)
 }
 
-func findAsset(assets []releaseAsset, name string) (string, string) {
-	var assetURL string
-	var checksumURL string
-	checksumName := name + ".sha256"
-	for _, asset := range assets {
-		switch asset.Name {
-		case name:
-			assetURL = asset.URL
-		case checksumName:
-			checksumURL = asset.URL
+func newestRelease(releases []ReleaseInfo, channel string) *ReleaseInfo {
+	var best *ReleaseInfo
+	var bestVersion version
+	for i := range releases {
+		rel := &releases[i]
+		parsed, ok := parseVersion(rel.Tag)
+		if rel.Draft || !ok {
+			continue
+		}
+		if channel == ChannelStable && (rel.Prerelease || parsed.prerelease()) {
+			continue
+		}
+		if best == nil || parsed.compare(bestVersion) > 0 {
+			best, bestVersion = rel, parsed
 		}
 	}
-	return assetURL, checksumURL
+	return best
 }
 
-func downloadToFile(ctx context.Context, url string, dst *os.File, checksumURL string) error {
-	if err := dst.Truncate(0); err != nil {
-		return fmt.Errorf("truncate temp file: %w", err)
+// verifiedChecksum downloads the checksum of the asset and checks its
+// signature. It returns "" only when insecure allowed a release
+// without a checksum.
+func verifiedChecksum(ctx context.Context, source ReleaseSource, assetName string, checksumAsset *Asset, signatureAsset *Asset, insecure bool) (string, error) {
+	if checksumAsset == nil {
+		if insecure {
+			return "", nil
+		}
+		return "", fmt.Errorf("%w: no checksum published", ErrUnverified)
 	}
-	if _, err := dst.Seek(0, 0); err != nil {
-		return fmt.Errorf("seek temp file: %w", err)
+
+	checksum, err := downloadSmall(ctx, source, *checksumAsset, "checksum")
+	if err != nil {
+		return "", err
 	}
 
-	expectedHash := ""
-	if checksumURL != "" {
-		hash, err := downloadChecksum(ctx, checksumURL)
+	switch {
+	case signatureAsset != nil && PublicKey != "":
+		signature, err := downloadSmall(ctx, source, *signatureAsset, "signature")
 		if err != nil {
-			return err
+			return "", err
+		}
+		if err := verifySignature(checksum, signature, PublicKey); err != nil {
+			return "", err
 		}
-		expectedHash = hash
+	case insecure:
+	case signatureAsset == nil:
+		return "", fmt.Errorf("%w: checksum is not signed", ErrUnverified)
+	default:
+		return "", fmt.Errorf("%w: this build has no release public key", ErrUnverified)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return err
+	return checksumFor(checksum, assetName)
+}
+
+func downloadToFile(ctx context.Context, source ReleaseSource, asset Asset, dst *os.File, expectedHash string) error {
+	if err := dst.Truncate(0); err != nil {
+		return fmt.Errorf("truncate temp file: %w", err)
+	}
+	if _, err := dst.Seek(0, 0); err != nil {
+		return fmt.Errorf("seek temp file: %w", err)
 	}
-	req.Header.Set("User-Agent", "vcontext")
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+
+	body, err := source.Open(ctx, asset)
 	if err != nil {
 		return fmt.Errorf("download asset: %w", err)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return fmt.Errorf("download asset: %s", strings.TrimSpace(string(body)))
-	}
+	defer body.Close()
 
 	hasher := sha256.New()
 	writer := io.MultiWriter(dst, hasher)
-	if _, err := io.Copy(writer, resp.Body); err != nil {
+	if _, err := io.Copy(writer, body); err != nil {
 		return fmt.Errorf("download asset: %w", err)
 	}
 
@@ -200,68 +332,34 @@ func downloadToFile(ctx context.Context, url string, dst *os.File, checksumURL s
 	return nil
 }
 
-func downloadChecksum(ctx context.Context, url string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return "", err
-	}
-	req.Header.Set("User-Agent", "vcontext")
-	client := &http.Client{Timeout: 20 * time.Second}
-	resp, err := client.Do(req)
+// downloadSmall fetches a checksum or signature file, at most 64 KiB;
+// what names it in errors.
+func downloadSmall(ctx context.Context, source ReleaseSource, asset Asset, what string) ([]byte, error) {
+	body, err := source.Open(ctx, asset)
 	if err != nil {
-		return "", fmt.Errorf("download checksum: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
-		return "", fmt.Errorf("download checksum: %s", strings.TrimSpace(string(body)))
+		return nil, fmt.Errorf("download %s: %w", what, err)
 	}
+	defer body.Close()
 
-	data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	data, err := io.ReadAll(io.LimitReader(body, 64*1024))
 	if err != nil {
-		return "", fmt.Errorf("read checksum: %w", err)
+		return nil, fmt.Errorf("read %s: %w", what, err)
 	}
-
-	fields := strings.Fields(string(data))
-	if len(fields) == 0 {
-		return "", errors.New("checksum file empty")
-	}
-
-	return strings.ToLower(fields[0]), nil
+	return data, nil
 }
 
+// replaceBinary moves the running binary aside as the rollback copy and
+// puts the new one in its place. Renaming works on Windows too, where the
+// running binary cannot be overwritten.
 func replaceBinary(tmpPath string, exePath string) error {
-	if runtime.GOOS == "windows" {
-		backup := exePath + ".old"
-		_ = os.Remove(backup)
-		if err := os.Rename(exePath, backup); err != nil {
-			return fmt.Errorf("replace binary: %w", err)
-		}
-		if err := os.Rename(tmpPath, exePath); err != nil {
-			_ = os.Rename(backup, exePath)
-			return fmt.Errorf("replace binary: %w", err)
-		}
-		_ = os.Remove(backup)
-		return nil
+	backup := exePath + backupSuffix
+	_ = os.Remove(backup)
+	if err := os.Rename(exePath, backup); err != nil {
+		return fmt.Errorf("keep previous binary: %w", err)
 	}
-
 	if err := os.Rename(tmpPath, exePath); err != nil {
+		_ = os.Rename(backup, exePath)
 		return fmt.Errorf("replace binary: %w", err)
 	}
-
 	return nil
 }
-
-func isSameVersion(latest string, current string) bool {
-	current = strings.TrimSpace(current)
-	if current == "" || current == "dev" {
-		return false
-	}
-	return normalizeVersion(latest) == normalizeVersion(current)
-}
-
-func normalizeVersion(version string) string {
-	version = strings.TrimSpace(version)
-	version = strings.TrimPrefix(version, "v")
-	return version
-}
@@ -0,0 +1,363 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// fakeRelease serves a GitHub releases API with one release, v2.0.0, whose
+// binary, checksum and signature are the given bytes. An empty checksum or
+// signature is not published.
+func fakeRelease(t *testing.T, binary []byte, checksum string, signature string) *httptest.Server {
+	t.Helper()
+	name := buildAssetName()
+	assets := map[string]string{name: string(binary)}
+	if checksum != "" {
+		assets[name+".sha256"] = checksum
+	}
+	if signature != "" {
+		assets[name+".sha256.sig"] = signature
+	}
+	return fakeReleaseAssets(t, assets)
+}
+
+// fakeReleaseAssets serves a GitHub releases API with one release, v2.0.0,
+// whose assets have the given names and contents. Like the assets of a
+// private repository, they are only served through the API.
+func fakeReleaseAssets(t *testing.T, assets map[string]string) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/org/vcontext/releases" {
+			list := []map[string]string{}
+			for name := range assets {
+				list = append(list, map[string]string{
+					"name":                 name,
+					"url":                  server.URL + "/repos/org/vcontext/releases/assets/" + name,
+					"browser_download_url": server.URL + "/dl/" + name,
+				})
+			}
+			_ = json.NewEncoder(w).Encode([]map[string]any{{"tag_name": "v2.0.0", "assets": list}})
+			return
+		}
+		name, ok := strings.CutPrefix(r.URL.Path, "/repos/org/vcontext/releases/assets/")
+		body, found := assets[name]
+		if !ok || !found {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Accept") != "application/octet-stream" {
+			_ = json.NewEncoder(w).Encode(map[string]string{"name": name})
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func sign(key ed25519.PrivateKey, data string) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(data)))
+}
+
+func TestInstall(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey := PublicKey
+	t.Cleanup(func() { PublicKey = oldKey })
+
+	binary := []byte("new vcontext binary")
+	hash := fmt.Sprintf("%x", sha256.Sum256(binary))
+	name := buildAssetName()
+
+	tests := []struct {
+		name      string
+		checksum  string
+		signature func(checksum string) string
+		// noKey builds without a release public key.
+		noKey    bool
+		insecure bool
+		wantErr  error
+		wantText string
+	}{
+		{
+			name:      "signed checksum",
+			checksum:  hash + "  " + name + "\n",
+			signature: func(checksum string) string { return sign(private, checksum) },
+		},
+		{
+			name:      "checksum hashed from another directory",
+			checksum:  hash + "  dist/" + name + "\n",
+			signature: func(checksum string) string { return sign(private, checksum) },
+		},
+		{
+			name:      "bad signature",
+			checksum:  hash + "  " + name + "\n",
+			signature: func(checksum string) string { return sign(otherKey, checksum) },
+			wantErr:   ErrBadSignature,
+		},
+		{
+			name:      "checksum of another asset",
+			checksum:  hash + "  vcontext_plan9_mips\n",
+			signature: func(checksum string) string { return sign(private, checksum) },
+			wantText:  "checksum file is for vcontext_plan9_mips",
+		},
+		{
+			name:      "tampered binary",
+			checksum:  strings.Repeat("0", 64) + "  " + name + "\n",
+			signature: func(checksum string) string { return sign(private, checksum) },
+			wantText:  "checksum mismatch",
+		},
+		{
+			name:      "unsigned checksum",
+			checksum:  hash + "  " + name + "\n",
+			signature: func(string) string { return "" },
+			wantErr:   ErrUnverified,
+		},
+		{
+			name:      "no checksum",
+			signature: func(string) string { return "" },
+			wantErr:   ErrUnverified,
+		},
+		{
+			name:      "build without public key",
+			checksum:  hash + "  " + name + "\n",
+			signature: func(checksum string) string { return sign(private, checksum) },
+			noKey:     true,
+			wantErr:   ErrUnverified,
+		},
+		{
+			name:      "insecure unsigned checksum",
+			checksum:  hash + "  " + name + "\n",
+			signature: func(string) string { return "" },
+			insecure:  true,
+		},
+		{
+			name:      "insecure without checksum",
+			signature: func(string) string { return "" },
+			insecure:  true,
+		},
+		{
+			name:      "insecure build without public key",
+			checksum:  hash + "  " + name + "\n",
+			signature: func(checksum string) string { return sign(otherKey, checksum) },
+			noKey:     true,
+			insecure:  true,
+		},
+		{
+			name:      "insecure tampered binary",
+			checksum:  strings.Repeat("0", 64) + "  " + name + "\n",
+			signature: func(string) string { return "" },
+			insecure:  true,
+			wantText:  "checksum mismatch",
+		},
+		{
+			name:      "insecure bad signature",
+			checksum:  hash + "  " + name + "\n",
+			signature: func(checksum string) string { return sign(otherKey, checksum) },
+			insecure:  true,
+			wantErr:   ErrBadSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			PublicKey = base64.StdEncoding.EncodeToString(public)
+			if tt.noKey {
+				PublicKey = ""
+			}
+			server := fakeRelease(t, binary, tt.checksum, tt.signature(tt.checksum))
+			source, err := NewSource(SourceGitHub, server.URL, "org/vcontext", false)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			exePath := filepath.Join(t.TempDir(), "vcontext")
+			if err := os.WriteFile(exePath, []byte("old vcontext binary"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			tag, err := install(context.Background(), Options{Source: source, CurrentVersion: "v1.0.0", Insecure: tt.insecure}, exePath)
+			got, _ := os.ReadFile(exePath)
+			if tt.wantErr == nil && tt.wantText == "" {
+				if err != nil {
+					t.Fatalf("install: %v", err)
+				}
+				if tag != "v2.0.0" || string(got) != string(binary) {
+					t.Fatalf("installed %s %q, want v2.0.0 %q", tag, got, binary)
+				}
+				if previous, _ := os.ReadFile(exePath + backupSuffix); string(previous) != "old vcontext binary" {
+					t.Fatalf("previous binary = %q", previous)
+				}
+				return
+			}
+
+			if err == nil {
+				t.Fatal("install succeeded, want an error")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("install: %v, want %v", err, tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantText) {
+				t.Fatalf("install: %v, want it to mention %q", err, tt.wantText)
+			}
+			if string(got) != "old vcontext binary" {
+				t.Fatalf("binary replaced after a failed update: %q", got)
+			}
+		})
+	}
+}
+
+func TestInstallArchive(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey := PublicKey
+	PublicKey = base64.StdEncoding.EncodeToString(public)
+	t.Cleanup(func() { PublicKey = oldKey })
+
+	arch := runtime.GOARCH
+	if arch == "amd64" {
+		arch = "x86_64"
+	}
+	binary := "new vcontext binary"
+	archive := string(tarGz(t, map[string]string{"vcontext-2.0.0/" + binaryName(): binary}))
+	name := "vcontext_2.0.0_" + runtime.GOOS + "_" + arch + ".tar.gz"
+	checksums := fmt.Sprintf("%x  vcontext_2.0.0_plan9_mips.tar.gz\n%x  %s\n", sha256.Sum256([]byte("other")), sha256.Sum256([]byte(archive)), name)
+
+	server := fakeReleaseAssets(t, map[string]string{
+		"vcontext_2.0.0_plan9_mips.tar.gz": "other",
+		name:                               archive,
+		"checksums.txt":                    checksums,
+		"checksums.txt.sig":                sign(private, checksums),
+	})
+	source, err := NewSource(SourceGitHub, server.URL, "org/vcontext", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exePath := filepath.Join(t.TempDir(), "vcontext")
+	if err := os.WriteFile(exePath, []byte("old vcontext binary"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tag, err := install(context.Background(), Options{Source: source, CurrentVersion: "v1.0.0"}, exePath)
+	if err != nil {
+		t.Fatalf("install: %v", err)
+	}
+	if got, _ := os.ReadFile(exePath); tag != "v2.0.0" || string(got) != binary {
+		t.Fatalf("installed %s %q, want v2.0.0 %q", tag, got, binary)
+	}
+	info, err := os.Stat(exePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o100 == 0 {
+		t.Fatalf("installed binary has mode %v, want it executable", info.Mode())
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(exePath)); len(entries) != 2 {
+		t.Fatalf("install left %d files behind, want the binary and its backup", len(entries))
+	}
+}
+
+func TestInstallOnlyNewer(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey := PublicKey
+	PublicKey = base64.StdEncoding.EncodeToString(public)
+	t.Cleanup(func() { PublicKey = oldKey })
+
+	binary := []byte("new vcontext binary")
+	checksum := fmt.Sprintf("%x  %s\n", sha256.Sum256(binary), buildAssetName())
+	server := fakeRelease(t, binary, checksum, sign(private, checksum))
+	source, err := NewSource(SourceGitHub, server.URL, "org/vcontext", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		current string
+		install bool
+	}{
+		{"v1.0.0", true},
+		{"v2.0.0-rc.1", true},
+		{"dev", true},
+		{"", true},
+		{"v2.0.0", false},
+		{"2.0.0", false},
+		{"v2.0.1", false},
+		{"v10.0.0", false},
+	} {
+		t.Run(tt.current, func(t *testing.T) {
+			exePath := filepath.Join(t.TempDir(), "vcontext")
+			if err := os.WriteFile(exePath, []byte("old vcontext binary"), 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := install(context.Background(), Options{Source: source, CurrentVersion: tt.current}, exePath)
+			got, _ := os.ReadFile(exePath)
+			if tt.install {
+				if err != nil || string(got) != string(binary) {
+					t.Fatalf("install over %q = %v, binary %q", tt.current, err, got)
+				}
+				return
+			}
+			if !errors.Is(err, ErrAlreadyLatest) {
+				t.Fatalf("install over %q = %v, want %v", tt.current, err, ErrAlreadyLatest)
+			}
+			if string(got) != "old vcontext binary" {
+				t.Fatalf("install over %q replaced the binary with %q", tt.current, got)
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	exePath := filepath.Join(t.TempDir(), "vcontext")
+	if err := rollback(exePath); !errors.Is(err, ErrNoBackup) {
+		t.Fatalf("rollback without a backup = %v, want %v", err, ErrNoBackup)
+	}
+
+	if err := os.WriteFile(exePath, []byte("new"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(exePath+backupSuffix, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second rollback undoes the first.
+	for _, want := range []string{"old", "new"} {
+		if err := rollback(exePath); err != nil {
+			t.Fatalf("rollback: %v", err)
+		}
+		current, _ := os.ReadFile(exePath)
+		previous, _ := os.ReadFile(exePath + backupSuffix)
+		if string(current) != want || string(previous) == want {
+			t.Fatalf("after rollback: binary %q, backup %q, want binary %q", current, previous, want)
+		}
+	}
+	if _, err := os.Stat(exePath + ".rollback"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("rollback left its swap file behind: %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PublicKey is the base64 encoded ed25519 key that signs the checksums of
+// official releases. Release builds set it with
+// -ldflags "-X vcontext/internal/update.PublicKey=<key>"; builds without a
+// key cannot verify updates and only install with Options.Insecure.
+var PublicKey = ""
+
+var (
+	// ErrUnverified means the release lacks a checksum or signature, or the
+	// binary has no key to check it with.
+	ErrUnverified = errors.New("release cannot be verified")
+	// ErrBadSignature means the checksum was not signed by PublicKey.
+	ErrBadSignature = errors.New("checksum signature is invalid")
+)
+
+func verifySignature(data []byte, signature []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: embedded public key is malformed", ErrUnverified)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: signature is malformed", ErrBadSignature)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return ErrBadSignature
+	}
+	return nil
+}
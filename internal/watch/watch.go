@@ -0,0 +1,256 @@
+package watch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"vcontext/internal/db"
+	"vcontext/internal/importer"
+)
+
+const (
+	DefaultInterval   = 2 * time.Second
+	defaultImportance = 3
+)
+
+// IgnoreFiles are read from the root of a watched directory.
+var IgnoreFiles = []string{".gitignore", ".vcontextignore"}
+
+type Options struct {
+	Interval time.Duration
+	// Exclude adds .gitignore-style patterns to those found in IgnoreFiles.
+	Exclude []string
+	// Namespace is applied to items whose front matter does not set one.
+	Namespace *string
+	Logger    *slog.Logger
+}
+
+type Result struct {
+	Indexed   int `json:"indexed"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+	Failed    int `json:"failed"`
+}
+
+// Watcher mirrors the Markdown and text files below a directory into memory
+// by polling. The items of a file carry the source "file:<absolute path>";
+// the file owns only the items it created.
+type Watcher struct {
+	store  *db.DB
+	root   string
+	ignore Ignore
+	opts   Options
+	// failed remembers the mtime of files that could not be parsed so they
+	// are retried only after they change.
+	failed map[string]int64
+}
+
+func New(store *db.DB, dir string, opts Options) (*Watcher, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	w := &Watcher{store: store, root: root, opts: opts, failed: map[string]int64{}}
+	for _, name := range IgnoreFiles {
+		if err := w.ignore.AddFile(filepath.Join(root, name)); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.ignore.Add(opts.Exclude...); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Watcher) Root() string {
+	return w.root
+}
+
+// Run syncs immediately and then every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Sync(ctx); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.opts.Logger.Error("watch sync failed", "dir", w.root, "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync brings memory in line with the directory once.
+func (w *Watcher) Sync(ctx context.Context) (Result, error) {
+	var result Result
+
+	tracked, err := w.store.SourceFiles(ctx, w.root+string(filepath.Separator))
+	if err != nil {
+		return result, err
+	}
+	known := make(map[string]db.SourceFile, len(tracked))
+	for _, file := range tracked {
+		known[file.Path] = file
+	}
+
+	seen := map[string]bool{}
+	complete := true
+	err = filepath.WalkDir(w.root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			w.opts.Logger.Warn("watch cannot read path", "path", path, "err", err)
+			complete = false
+			return nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if path == w.root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(w.root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(entry.Name(), ".") || w.ignore.Match(rel, entry.IsDir()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		typ := importer.DetectType(path)
+		if typ != importer.TypeMarkdown && typ != importer.TypeText {
+			return nil
+		}
+
+		seen[path] = true
+		prev, ok := known[path]
+		return w.syncFile(ctx, path, typ, prev, ok, &result)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if !complete {
+		return result, nil
+	}
+	for _, file := range tracked {
+		if seen[file.Path] {
+			continue
+		}
+		if err := w.store.DeleteSourceFile(ctx, file); err != nil {
+			return result, err
+		}
+		delete(w.failed, file.Path)
+		result.Removed++
+		w.opts.Logger.Info("removed file from memory", "source", file.Source)
+	}
+
+	return result, nil
+}
+
+func (w *Watcher) syncFile(ctx context.Context, path string, typ importer.Type, prev db.SourceFile, tracked bool, result *Result) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		w.opts.Logger.Warn("watch cannot stat file", "path", path, "err", err)
+		result.Failed++
+		return nil
+	}
+
+	file := db.SourceFile{
+		Path:    path,
+		Source:  sourceFor(path),
+		ModTime: info.ModTime().UnixNano(),
+		Size:    info.Size(),
+	}
+	if tracked && prev.Source == file.Source && prev.ModTime == file.ModTime && prev.Size == file.Size {
+		result.Unchanged++
+		return nil
+	}
+	if mtime, ok := w.failed[path]; ok && mtime == file.ModTime {
+		result.Failed++
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		w.opts.Logger.Warn("watch cannot read file", "path", path, "err", err)
+		result.Failed++
+		return nil
+	}
+	file.Hash = db.ContentHash(string(data))
+	if tracked && prev.Source == file.Source && prev.Hash == file.Hash {
+		result.Unchanged++
+		return w.store.TouchSourceFile(ctx, file)
+	}
+
+	items, err := importer.ParseFile(path, data, info.ModTime(), typ)
+	if err != nil {
+		w.opts.Logger.Warn("watch cannot parse file", "path", path, "err", err)
+		w.failed[path] = file.ModTime
+		result.Failed++
+		return nil
+	}
+	delete(w.failed, path)
+
+	for i := range items {
+		items[i].ID = uuid.NewString()
+		items[i].Source = &file.Source
+		if items[i].Namespace == nil {
+			items[i].Namespace = w.opts.Namespace
+		}
+		if items[i].Importance == 0 {
+			items[i].Importance = defaultImportance
+		}
+	}
+
+	if err := w.store.ReplaceSourceFile(ctx, file, items); err != nil {
+		return err
+	}
+	result.Indexed++
+	w.opts.Logger.Info("indexed file into memory", "source", file.Source, "items", len(items))
+	return nil
+}
+
+// sourceFor names the items of a watched file after its absolute path, so
+// the same relative path in two watched trees, or a watcher started from
+// another directory, never shares a source.
+func sourceFor(path string) string {
+	return "file:" + filepath.ToSlash(path)
+}
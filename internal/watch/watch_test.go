@@ -0,0 +1,100 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"vcontext/internal/db"
+)
+
+func writeNote(t *testing.T, path string, text string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func syncOnce(t *testing.T, w *Watcher) Result {
+	t.Helper()
+	result, err := w.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("sync %s: %v", w.Root(), err)
+	}
+	return result
+}
+
+func contents(t *testing.T, store *db.DB) map[string]bool {
+	t.Helper()
+	items, err := store.ListContext(context.Background(), db.ListFilter{Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]bool{}
+	for _, item := range items {
+		got[item.Content] = true
+	}
+	return got
+}
+
+// Watchers of two trees with the same layout share a database without
+// touching each other's items, or items saved with a file's source.
+func TestWatchersOwnOnlyTheirItems(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := db.Open(filepath.Join(dir, "vcontext.db"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	rootA, rootB := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeNote(t, filepath.Join(rootA, "docs", "README.md"), "note of a\n")
+	writeNote(t, filepath.Join(rootB, "docs", "README.md"), "note of b\n")
+
+	watchers := []*Watcher{}
+	for _, root := range []string{rootA, rootB} {
+		w, err := New(store, root, Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		watchers = append(watchers, w)
+		if result := syncOnce(t, w); result.Indexed != 1 {
+			t.Fatalf("sync %s = %+v", root, result)
+		}
+	}
+
+	source := sourceFor(filepath.Join(watchers[0].Root(), "docs", "README.md"))
+	if err := store.InsertContext(ctx, db.ContextItem{ID: "saved", CreatedAt: 1, Source: &source, Content: "saved by hand", Importance: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	writeNote(t, filepath.Join(rootA, "docs", "README.md"), "note of a, edited\n")
+	if result := syncOnce(t, watchers[0]); result.Indexed != 1 {
+		t.Fatalf("sync after edit = %+v", result)
+	}
+	got := contents(t, store)
+	for _, want := range []string{"note of a, edited", "note of b", "saved by hand"} {
+		if !got[want] {
+			t.Fatalf("items after edit = %v, missing %q", got, want)
+		}
+	}
+	if got["note of a"] {
+		t.Fatalf("old version of the edited note kept: %v", got)
+	}
+
+	if err := os.Remove(filepath.Join(rootA, "docs", "README.md")); err != nil {
+		t.Fatal(err)
+	}
+	if result := syncOnce(t, watchers[0]); result.Removed != 1 {
+		t.Fatalf("sync after delete = %+v", result)
+	}
+	got = contents(t, store)
+	if len(got) != 2 || !got["note of b"] || !got["saved by hand"] {
+		t.Fatalf("items after delete = %v", got)
+	}
+}
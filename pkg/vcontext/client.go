@@ -1,30 +1,63 @@
 package vcontext
 
 import (
-	"bufio"
-	"bytes"
 	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"io"
 	"strconv"
-	"strings"
-	"sync"
+	"sync/atomic"
 )
 
+type transport interface {
+	roundTrip(ctx context.Context, req JSONRPCRequest) (*JSONRPCResponse, error)
+	notify(ctx context.Context, method string, params any) error
+	setNotificationHandler(handler NotificationHandler)
+	close() error
+}
+
+type Memory interface {
+	SaveContext(ctx context.Context, params SaveContextParams) (SaveContextResult, error)
+	SearchContext(ctx context.Context, params SearchContextParams) (SearchContextResult, error)
+	GetContext(ctx context.Context, params GetContextParams) (ContextItem, error)
+	BulkSaveContext(ctx context.Context, params BulkSaveContextParams) (BulkSaveContextResult, error)
+	Reindex(ctx context.Context) (ReindexResult, error)
+	MemoryStats(ctx context.Context, params MemoryStatsParams) (MemoryStatsResult, error)
+	Close() error
+}
+
+var _ Memory = (*Client)(nil)
+
 type Client struct {
-	reader *bufio.Reader
-	writer io.Writer
-	mu     sync.Mutex
-	nextID uint64
+	conn   transport
+	nextID atomic.Uint64
 }
 
 func NewClient(r io.Reader, w io.Writer) *Client {
-	return &Client{
-		reader: bufio.NewReader(r),
-		writer: w,
+	return &Client{conn: newStreamConn(r, w)}
+}
+
+func (c *Client) SetNotificationHandler(handler NotificationHandler) {
+	c.conn.setNotificationHandler(handler)
+}
+
+func (c *Client) Close() error {
+	return c.conn.close()
+}
+
+func (c *Client) Initialize(ctx context.Context) (InitializeResult, error) {
+	var result InitializeResult
+	params := InitializeParams{
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    map[string]any{},
+		ClientInfo:      Implementation{Name: "vcontext-go", Version: "dev"},
 	}
+	if err := c.call(ctx, "initialize", params, &result); err != nil {
+		return result, err
+	}
+	if err := c.conn.notify(ctx, "notifications/initialized", nil); err != nil {
+		return result, err
+	}
+	return result, nil
 }
 
 func (c *Client) SaveContext(ctx context.Context, params SaveContextParams) (SaveContextResult, error) {
@@ -45,70 +78,46 @@ func (c *Client) GetContext(ctx context.Context, params GetContextParams) (Conte
 	return result, err
 }
 
-func (c *Client) call(ctx context.Context, method string, params any, out any) error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+func (c *Client) BulkSaveContext(ctx context.Context, params BulkSaveContextParams) (BulkSaveContextResult, error) {
+	var result BulkSaveContextResult
+	err := c.call(ctx, "tools/bulk_save_context/invoke", params, &result)
+	return result, err
+}
 
-	c.nextID++
-	idStr := strconv.FormatUint(c.nextID, 10)
+func (c *Client) Reindex(ctx context.Context) (ReindexResult, error) {
+	var result ReindexResult
+	err := c.call(ctx, "tools/reindex/invoke", struct{}{}, &result)
+	return result, err
+}
+
+func (c *Client) MemoryStats(ctx context.Context, params MemoryStatsParams) (MemoryStatsResult, error) {
+	var result MemoryStatsResult
+	err := c.call(ctx, "tools/memory_stats/invoke", params, &result)
+	return result, err
+}
 
+func (c *Client) call(ctx context.Context, method string, params any, out any) error {
+	id := c.nextID.Add(1)
 	req := JSONRPCRequest{
 		JSONRPC: "2.0",
-		ID:      json.RawMessage(idStr),
+		ID:      json.RawMessage(strconv.FormatUint(id, 10)),
 		Method:  method,
 		Params:  params,
 	}
 
-	payload, err := json.Marshal(req)
+	resp, err := c.conn.roundTrip(ctx, req)
 	if err != nil {
 		return err
 	}
-	payload = append(payload, '\n')
-	if _, err := c.writer.Write(payload); err != nil {
-		return err
-	}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-		}
-
-		line, err := c.reader.ReadBytes('\n')
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return fmt.Errorf("connection closed")
-			}
-			return err
-		}
-
-		line = bytes.TrimSpace(line)
-		if len(line) == 0 {
-			continue
-		}
-
-		var resp JSONRPCResponse
-		if err := json.Unmarshal(line, &resp); err != nil {
-			continue
-		}
-
-		if strings.TrimSpace(string(resp.ID)) != idStr {
-			continue
-		}
-
-		if resp.Error != nil {
-			return resp.Error
-		}
-		if out == nil {
-			return nil
-		}
-		if len(resp.Result) == 0 {
-			return nil
-		}
-		if err := json.Unmarshal(resp.Result, out); err != nil {
-			return err
-		}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	if len(resp.Result) == 0 {
 		return nil
 	}
+	return json.Unmarshal(resp.Result, out)
 }
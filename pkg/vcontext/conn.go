@@ -0,0 +1,285 @@
+package vcontext
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// NotificationHandler receives the notifications of the server in the
+// order they arrive. It runs on a goroutine of its own, not on the one
+// reading responses, so it may call back into the Client.
+type NotificationHandler func(method string, params json.RawMessage)
+
+// notifier hands notifications to the handler one at a time, in order, off
+// the read loop.
+type notifier struct {
+	mu      sync.Mutex
+	handler NotificationHandler
+	queue   []queuedNote
+	running bool
+}
+
+type queuedNote struct {
+	handler NotificationHandler
+	method  string
+	params  json.RawMessage
+}
+
+func (n *notifier) setHandler(handler NotificationHandler) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.handler = handler
+}
+
+func (n *notifier) deliver(method string, params json.RawMessage) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.handler == nil {
+		return
+	}
+	n.queue = append(n.queue, queuedNote{handler: n.handler, method: method, params: params})
+	if !n.running {
+		n.running = true
+		go n.run()
+	}
+}
+
+func (n *notifier) run() {
+	for {
+		n.mu.Lock()
+		if len(n.queue) == 0 {
+			n.running = false
+			n.mu.Unlock()
+			return
+		}
+		note := n.queue[0]
+		n.queue = n.queue[1:]
+		n.mu.Unlock()
+
+		note.handler(note.method, note.params)
+	}
+}
+
+type incomingMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+type outgoingResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+type CancelledParams struct {
+	RequestID any    `json:"requestId"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type streamConn struct {
+	reader io.Reader
+	writer io.Writer
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[string]chan *JSONRPCResponse
+	notes   notifier
+	closed  chan struct{}
+	err     error
+	// readDone is closed when the read loop has stopped reading.
+	readDone chan struct{}
+}
+
+func newStreamConn(r io.Reader, w io.Writer) *streamConn {
+	c := &streamConn{
+		reader:   r,
+		writer:   w,
+		pending:  make(map[string]chan *JSONRPCResponse),
+		closed:   make(chan struct{}),
+		readDone: make(chan struct{}),
+	}
+	go c.readLoop()
+	return c
+}
+
+func (c *streamConn) setNotificationHandler(handler NotificationHandler) {
+	c.notes.setHandler(handler)
+}
+
+func (c *streamConn) roundTrip(ctx context.Context, req JSONRPCRequest) (*JSONRPCResponse, error) {
+	key := idKey(req.ID)
+	ch := make(chan *JSONRPCResponse, 1)
+
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.pending[key] = ch
+	c.mu.Unlock()
+
+	if err := c.writeJSON(req); err != nil {
+		c.forget(key)
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-ctx.Done():
+		c.forget(key)
+		_ = c.writeJSON(JSONRPCRequest{
+			JSONRPC: "2.0",
+			Method:  "notifications/cancelled",
+			Params:  CancelledParams{RequestID: req.ID, Reason: ctx.Err().Error()},
+		})
+		return nil, contextError(ctx)
+	case <-c.closed:
+		c.mu.Lock()
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+}
+
+func (c *streamConn) notify(ctx context.Context, method string, params any) error {
+	return c.writeJSON(JSONRPCRequest{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+func (c *streamConn) close() error {
+	c.fail(ErrConnectionClosed)
+
+	var errs []error
+	if closer, ok := c.writer.(io.Closer); ok {
+		errs = append(errs, closer.Close())
+	}
+	if closer, ok := c.reader.(io.Closer); ok && any(c.reader) != any(c.writer) {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (c *streamConn) writeJSON(msg any) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	payload = append(payload, '\n')
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := c.writer.Write(payload); err != nil {
+		return fmt.Errorf("write request: %w", err)
+	}
+	return nil
+}
+
+func (c *streamConn) forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, key)
+}
+
+func (c *streamConn) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	c.pending = make(map[string]chan *JSONRPCResponse)
+	close(c.closed)
+}
+
+func (c *streamConn) readLoop() {
+	defer close(c.readDone)
+	reader := bufio.NewReader(c.reader)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			c.dispatch(bytes.TrimSpace(line))
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				c.fail(ErrConnectionClosed)
+			} else {
+				c.fail(fmt.Errorf("read response: %w", err))
+			}
+			return
+		}
+	}
+}
+
+func (c *streamConn) dispatch(line []byte) {
+	var msg incomingMessage
+	if err := json.Unmarshal(line, &msg); err != nil {
+		return
+	}
+
+	if msg.Method != "" {
+		if len(msg.ID) > 0 && string(msg.ID) != "null" {
+			c.answerServerRequest(msg)
+			return
+		}
+		c.notes.deliver(msg.Method, msg.Params)
+		return
+	}
+
+	key := idKey(msg.ID)
+	c.mu.Lock()
+	ch, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	ch <- &JSONRPCResponse{
+		JSONRPC: msg.JSONRPC,
+		ID:      msg.ID,
+		Result:  msg.Result,
+		Error:   msg.Error,
+	}
+}
+
+func (c *streamConn) answerServerRequest(msg incomingMessage) {
+	resp := outgoingResponse{JSONRPC: "2.0", ID: msg.ID}
+	if msg.Method == "ping" {
+		resp.Result = struct{}{}
+	} else {
+		resp.Error = &RPCError{Code: CodeMethodNotFound, Message: "method not found"}
+	}
+	go func() {
+		_ = c.writeJSON(resp)
+	}()
+}
+
+func idKey(id any) string {
+	switch value := id.(type) {
+	case json.RawMessage:
+		return strings.TrimSpace(string(value))
+	case nil:
+		return "null"
+	default:
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+}
@@ -0,0 +1,72 @@
+package vcontext
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// A notification handler that calls the client must not block the reader
+// that delivers the response it waits for.
+func TestNotificationHandlerCallsClient(t *testing.T) {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	client := NewClient(clientIn, clientOut)
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = serverOut.Close()
+	})
+
+	// The server keeps reading while the client is not, like a real one.
+	lines := make(chan string, 16)
+	go func() {
+		for line := range lines {
+			fmt.Fprintln(serverOut, line)
+		}
+	}()
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(serverIn)
+		for scanner.Scan() {
+			var req struct {
+				ID     json.RawMessage `json:"id"`
+				Method string          `json:"method"`
+			}
+			if json.Unmarshal(scanner.Bytes(), &req) != nil || len(req.ID) == 0 {
+				continue
+			}
+			if req.Method == "tools/search_context/invoke" {
+				lines <- `{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`
+			}
+			lines <- fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":{"id":"item-1","content":"hello"}}`, req.ID)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got := make(chan string, 1)
+	client.SetNotificationHandler(func(method string, params json.RawMessage) {
+		item, err := client.GetContext(ctx, GetContextParams{ID: "item-1"})
+		if err != nil {
+			got <- err.Error()
+			return
+		}
+		got <- item.Content
+	})
+
+	if _, err := client.SearchContext(ctx, SearchContextParams{Query: "q"}); err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	select {
+	case content := <-got:
+		if content != "hello" {
+			t.Fatalf("handler got %q, want hello", content)
+		}
+	case <-ctx.Done():
+		t.Fatal("handler deadlocked calling the client")
+	}
+}
@@ -0,0 +1,44 @@
+package vcontext
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type DialOptions struct {
+	Token      string
+	HTTPClient *http.Client
+	MaxRetries int
+	Process    Options
+}
+
+func Dial(ctx context.Context, target string, opts DialOptions) (*Client, error) {
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("parse target: %w", err)
+	}
+
+	switch strings.ToLower(parsed.Scheme) {
+	case "http", "https":
+		client := &Client{conn: newHTTPTransport(target, opts)}
+		if _, err := client.Initialize(ctx); err != nil {
+			_ = client.Close()
+			return nil, err
+		}
+		return client, nil
+	case "stdio":
+		procOpts := opts.Process
+		if path := parsed.Opaque + parsed.Path; path != "" {
+			procOpts.BinaryPath = path
+		}
+		if db := parsed.Query().Get("db"); db != "" {
+			procOpts.DBPath = db
+		}
+		return Spawn(ctx, procOpts)
+	default:
+		return nil, fmt.Errorf("unsupported target %q: want http://, https:// or stdio:", target)
+	}
+}
@@ -0,0 +1,242 @@
+package embedded
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"vcontext/internal/config"
+	"vcontext/internal/db"
+	"vcontext/internal/mcp"
+	"vcontext/internal/tools"
+	"vcontext/pkg/vcontext"
+)
+
+type Options struct {
+	Logger *slog.Logger
+	// Settings are the defaults applied to requests; zero fields keep the
+	// built-in defaults. LoadSettings reads them from the vcontext config.
+	Settings Settings
+}
+
+// Settings mirror the tools and memory sections of the vcontext config.
+type Settings struct {
+	DefaultTopK       int
+	MaxTopK           int
+	DefaultImportance int
+	// Namespace applies to requests that do not name one.
+	Namespace string
+	// Tags are added to the tags of every saved item.
+	Tags []string
+}
+
+// LoadSettings reads the settings from the layered configuration the vcontext
+// command uses: the user config, the project config and the environment.
+func LoadSettings() (Settings, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return Settings{}, err
+	}
+	return Settings{
+		DefaultTopK:       cfg.Tools.DefaultTopK,
+		MaxTopK:           cfg.Tools.MaxTopK,
+		DefaultImportance: cfg.Tools.DefaultImportance,
+		Namespace:         cfg.Memory.Namespace,
+		Tags:              cfg.Memory.Tags,
+	}, nil
+}
+
+func (s Settings) tools() tools.Settings {
+	settings := tools.DefaultSettings()
+	if s.DefaultTopK > 0 {
+		settings.DefaultTopK = s.DefaultTopK
+	}
+	if s.MaxTopK > 0 {
+		settings.MaxTopK = s.MaxTopK
+	}
+	if s.DefaultImportance > 0 {
+		settings.DefaultImportance = s.DefaultImportance
+	}
+	if s.Namespace != "" {
+		namespace := s.Namespace
+		settings.Namespace = &namespace
+	}
+	settings.Tags = s.Tags
+	return settings
+}
+
+var _ vcontext.Memory = (*Store)(nil)
+
+type Store struct {
+	db       *db.DB
+	settings tools.Settings
+}
+
+func Open(path string, opts Options) (*Store, error) {
+	store, err := db.Open(path, opts.Logger)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{db: store, settings: opts.Settings.tools()}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) SaveContext(ctx context.Context, params vcontext.SaveContextParams) (vcontext.SaveContextResult, error) {
+	result, rpcErr := tools.SaveContext(ctx, s.db, s.settings, toSaveParams(params))
+	if rpcErr != nil {
+		return vcontext.SaveContextResult{}, toError(rpcErr)
+	}
+	return vcontext.SaveContextResult{ID: result.ID, CreatedAt: result.CreatedAt}, nil
+}
+
+func (s *Store) SearchContext(ctx context.Context, params vcontext.SearchContextParams) (vcontext.SearchContextResult, error) {
+	result, rpcErr := tools.SearchContext(ctx, s.db, s.settings, tools.SearchContextParams{
+		Query:         params.Query,
+		TopK:          params.TopK,
+		Namespace:     params.Namespace,
+		ThreadID:      params.ThreadID,
+		MinImportance: params.MinImportance,
+	})
+	if rpcErr != nil {
+		return vcontext.SearchContextResult{}, toError(rpcErr)
+	}
+
+	items := make([]vcontext.SearchResult, 0, len(result.Items))
+	for _, item := range result.Items {
+		items = append(items, vcontext.SearchResult{
+			ID:         item.ID,
+			Title:      item.Title,
+			Namespace:  item.Namespace,
+			Source:     item.Source,
+			ThreadID:   item.ThreadID,
+			CreatedAt:  item.CreatedAt,
+			Importance: item.Importance,
+			Snippet:    item.Snippet,
+		})
+	}
+	return vcontext.SearchContextResult{Items: items}, nil
+}
+
+func (s *Store) GetContext(ctx context.Context, params vcontext.GetContextParams) (vcontext.ContextItem, error) {
+	item, rpcErr := tools.GetContext(ctx, s.db, tools.GetContextParams{ID: params.ID})
+	if rpcErr != nil {
+		return vcontext.ContextItem{}, toError(rpcErr)
+	}
+	return vcontext.ContextItem{
+		ID:         item.ID,
+		CreatedAt:  item.CreatedAt,
+		Namespace:  item.Namespace,
+		Source:     item.Source,
+		ThreadID:   item.ThreadID,
+		Role:       item.Role,
+		Title:      item.Title,
+		Content:    item.Content,
+		Tags:       item.Tags,
+		Importance: item.Importance,
+	}, nil
+}
+
+func (s *Store) BulkSaveContext(ctx context.Context, params vcontext.BulkSaveContextParams) (vcontext.BulkSaveContextResult, error) {
+	input := tools.BulkSaveContextParams{Items: make([]tools.SaveContextParams, 0, len(params.Items))}
+	for _, item := range params.Items {
+		input.Items = append(input.Items, toSaveParams(item))
+	}
+
+	result, rpcErr := tools.BulkSaveContext(ctx, s.db, s.settings, input)
+	if rpcErr != nil {
+		return vcontext.BulkSaveContextResult{}, toError(rpcErr)
+	}
+
+	items := make([]vcontext.SaveContextResult, 0, len(result.Items))
+	for _, item := range result.Items {
+		items = append(items, vcontext.SaveContextResult{ID: item.ID, CreatedAt: item.CreatedAt})
+	}
+	return vcontext.BulkSaveContextResult{Items: items}, nil
+}
+
+func (s *Store) Reindex(ctx context.Context) (vcontext.ReindexResult, error) {
+	result, rpcErr := tools.Reindex(ctx, s.db)
+	if rpcErr != nil {
+		return vcontext.ReindexResult{}, toError(rpcErr)
+	}
+	return vcontext.ReindexResult{Indexed: result.Indexed}, nil
+}
+
+func (s *Store) MemoryStats(ctx context.Context, params vcontext.MemoryStatsParams) (vcontext.MemoryStatsResult, error) {
+	stats, rpcErr := tools.MemoryStats(ctx, s.db, s.settings, tools.MemoryStatsParams{
+		Namespace: params.Namespace,
+		Top:       params.Top,
+		Days:      params.Days,
+	})
+	if rpcErr != nil {
+		return vcontext.MemoryStatsResult{}, toError(rpcErr)
+	}
+
+	result := vcontext.MemoryStatsResult{
+		Items:         stats.Items,
+		ContentBytes:  stats.ContentBytes,
+		FirstAt:       stats.FirstAt,
+		LastAt:        stats.LastAt,
+		Namespaces:    toValueCounts(stats.Namespaces),
+		Threads:       toValueCounts(stats.Threads),
+		Sources:       toValueCounts(stats.Sources),
+		Roles:         toValueCounts(stats.Roles),
+		Tags:          toValueCounts(stats.Tags),
+		Importance:    make([]vcontext.ImportanceCount, 0, len(stats.Importance)),
+		PerDay:        make([]vcontext.DayCount, 0, len(stats.PerDay)),
+		Largest:       make([]vcontext.ItemSize, 0, len(stats.Largest)),
+		DatabaseBytes: stats.DatabaseBytes,
+		IndexBytes:    stats.IndexBytes,
+	}
+	for _, count := range stats.Importance {
+		result.Importance = append(result.Importance, vcontext.ImportanceCount{Importance: count.Importance, Items: count.Items})
+	}
+	for _, count := range stats.PerDay {
+		result.PerDay = append(result.PerDay, vcontext.DayCount{Day: count.Day, Items: count.Items})
+	}
+	for _, item := range stats.Largest {
+		result.Largest = append(result.Largest, vcontext.ItemSize{
+			ID:        item.ID,
+			Title:     item.Title,
+			Namespace: item.Namespace,
+			ThreadID:  item.ThreadID,
+			CreatedAt: item.CreatedAt,
+			Bytes:     item.Bytes,
+		})
+	}
+	return result, nil
+}
+
+func toValueCounts(counts []db.ValueCount) []vcontext.ValueCount {
+	result := make([]vcontext.ValueCount, 0, len(counts))
+	for _, count := range counts {
+		result = append(result, vcontext.ValueCount{Value: count.Value, Items: count.Items, Bytes: count.Bytes})
+	}
+	return result
+}
+
+func toSaveParams(params vcontext.SaveContextParams) tools.SaveContextParams {
+	return tools.SaveContextParams{
+		Namespace:  params.Namespace,
+		Source:     params.Source,
+		ThreadID:   params.ThreadID,
+		Role:       params.Role,
+		Title:      params.Title,
+		Content:    params.Content,
+		Tags:       params.Tags,
+		Importance: params.Importance,
+	}
+}
+
+func toError(rpcErr *mcp.RPCError) error {
+	err := &vcontext.RPCError{Code: rpcErr.Code, Message: rpcErr.Message}
+	if rpcErr.Data != nil {
+		if data, marshalErr := json.Marshal(rpcErr.Data); marshalErr == nil {
+			err.Data = data
+		}
+	}
+	return err
+}
@@ -0,0 +1,101 @@
+package embedded
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"vcontext/pkg/vcontext"
+)
+
+func TestMemoryStats(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(filepath.Join(t.TempDir(), "vcontext.db"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	team := "team"
+	tags := []string{"db"}
+	for _, params := range []vcontext.SaveContextParams{
+		{Content: "we chose sqlite", Namespace: &team, Tags: &tags},
+		{Content: "fts5 for search", Namespace: &team},
+		{Content: "elsewhere"},
+	} {
+		if _, err := store.SaveContext(ctx, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := store.MemoryStats(ctx, vcontext.MemoryStatsParams{Namespace: &team})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Items != 2 || len(stats.Largest) != 2 || stats.DatabaseBytes == 0 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if len(stats.Tags) != 1 || *stats.Tags[0].Value != "db" || stats.Tags[0].Items != 1 {
+		t.Fatalf("tags = %+v", stats.Tags)
+	}
+	if len(stats.PerDay) != 1 || stats.PerDay[0].Items != 2 {
+		t.Fatalf("per day = %+v", stats.PerDay)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	ctx := context.Background()
+	store, err := Open(filepath.Join(t.TempDir(), "vcontext.db"), Options{Settings: Settings{
+		DefaultTopK:       1,
+		DefaultImportance: 4,
+		Namespace:         "team",
+		Tags:              []string{"agent"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	for _, content := range []string{"sqlite notes", "more sqlite notes"} {
+		if _, err := store.SaveContext(ctx, vcontext.SaveContextParams{Content: content}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, err := store.SearchContext(ctx, vcontext.SearchContextParams{Query: "sqlite"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Items) != 1 {
+		t.Fatalf("search returned %d items, want the default top_k of 1", len(found.Items))
+	}
+	item, err := store.GetContext(ctx, vcontext.GetContextParams{ID: found.Items[0].ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Namespace == nil || *item.Namespace != "team" || item.Importance != 4 || item.Tags == nil || len(*item.Tags) != 1 || (*item.Tags)[0] != "agent" {
+		t.Fatalf("saved item = %+v, want the configured defaults", item)
+	}
+
+	other := "other"
+	found, err = store.SearchContext(ctx, vcontext.SearchContextParams{Query: "sqlite", Namespace: &other})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found.Items) != 0 {
+		t.Fatalf("search of another namespace = %+v", found.Items)
+	}
+}
+
+func TestLoadSettings(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("VCONTEXT_NAMESPACE", "team")
+
+	settings, err := LoadSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.Namespace != "team" || settings.DefaultTopK == 0 || settings.MaxTopK == 0 {
+		t.Fatalf("settings = %+v", settings)
+	}
+}
@@ -0,0 +1,86 @@
+package vcontext
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+const (
+	CodeParse          = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternal       = -32603
+	CodeStorage        = -32000
+	CodeNotFound       = -32004
+)
+
+var (
+	ErrNotFound         = errors.New("vcontext: not found")
+	ErrInvalidParams    = errors.New("vcontext: invalid params")
+	ErrConnectionClosed = errors.New("vcontext: connection closed")
+	ErrTimeout          = errors.New("vcontext: timeout")
+)
+
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+type ErrorData struct {
+	Field      string `json:"field,omitempty"`
+	Constraint string `json:"constraint,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+func (e *RPCError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == CodeNotFound
+	case ErrInvalidParams:
+		return e.Code == CodeInvalidParams
+	default:
+		return false
+	}
+}
+
+func (e *RPCError) Details() (ErrorData, bool) {
+	var data ErrorData
+	if len(e.Data) == 0 {
+		return data, false
+	}
+	if err := json.Unmarshal(e.Data, &data); err != nil {
+		return data, false
+	}
+	return data, true
+}
+
+type timeoutError struct {
+	err error
+}
+
+func (e *timeoutError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrTimeout, e.err)
+}
+
+func (e *timeoutError) Is(target error) bool {
+	return target == ErrTimeout
+}
+
+func (e *timeoutError) Unwrap() error {
+	return e.err
+}
+
+func contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return &timeoutError{err: err}
+	}
+	return err
+}
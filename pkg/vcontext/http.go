@@ -0,0 +1,498 @@
+package vcontext
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sessionHeader         = "Mcp-Session-Id"
+	protocolVersionHeader = "MCP-Protocol-Version"
+	defaultHTTPRetries    = 3
+	minHTTPBackoff        = 200 * time.Millisecond
+	maxHTTPBackoff        = 5 * time.Second
+)
+
+var errSessionExpired = errors.New("session expired")
+
+var idempotentMethods = map[string]bool{
+	"initialize":                  true,
+	"ping":                        true,
+	"tools/list":                  true,
+	"prompts/list":                true,
+	"prompts/get":                 true,
+	"tools/search_context/invoke": true,
+	"tools/get_context/invoke":    true,
+	"tools/memory_stats/invoke":   true,
+}
+
+type httpTransport struct {
+	endpoint   string
+	token      string
+	client     *http.Client
+	maxRetries int
+
+	mu          sync.Mutex
+	sessionID   string
+	protocol    string
+	initPayload []byte
+	notes       notifier
+	streaming   bool
+
+	stop   context.CancelFunc
+	closed chan struct{}
+}
+
+func newHTTPTransport(endpoint string, opts DialOptions) *httpTransport {
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{}
+	}
+	retries := opts.MaxRetries
+	if retries == 0 {
+		retries = defaultHTTPRetries
+	}
+	if retries < 0 {
+		retries = 0
+	}
+	return &httpTransport{
+		endpoint:   endpoint,
+		token:      opts.Token,
+		client:     client,
+		maxRetries: retries,
+		closed:     make(chan struct{}),
+	}
+}
+
+func (t *httpTransport) roundTrip(ctx context.Context, req JSONRPCRequest) (*JSONRPCResponse, error) {
+	if t.isClosed() {
+		return nil, ErrConnectionClosed
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Close cancels the requests in flight.
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-t.closed:
+			cancel()
+		case <-callCtx.Done():
+		}
+	}()
+
+	attempts := 1
+	if idempotentMethods[req.Method] {
+		attempts += t.maxRetries
+	}
+
+	backoff := minHTTPBackoff
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-callCtx.Done():
+				if t.isClosed() {
+					return nil, ErrConnectionClosed
+				}
+				return nil, contextError(ctx)
+			}
+			backoff *= 2
+			if backoff > maxHTTPBackoff {
+				backoff = maxHTTPBackoff
+			}
+		}
+
+		resp, retry, err := t.post(callCtx, req, payload)
+		if errors.Is(err, errSessionExpired) && req.Method != "initialize" && !t.isClosed() {
+			if err := t.reinitialize(callCtx); err != nil {
+				if t.isClosed() {
+					return nil, ErrConnectionClosed
+				}
+				return nil, err
+			}
+			resp, retry, err = t.post(callCtx, req, payload)
+		}
+		if err == nil {
+			if req.Method == "initialize" && resp.Error == nil {
+				t.mu.Lock()
+				t.initPayload = payload
+				t.mu.Unlock()
+				t.startInitialized(resp)
+			}
+			return resp, nil
+		}
+		if t.isClosed() {
+			return nil, ErrConnectionClosed
+		}
+		if ctx.Err() != nil {
+			t.sendCancelled(req.ID, ctx.Err())
+			return nil, contextError(ctx)
+		}
+		lastErr = err
+		if !retry {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+func (t *httpTransport) post(ctx context.Context, req JSONRPCRequest, payload []byte) (*JSONRPCResponse, bool, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, false, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(httpReq)
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return nil, true, fmt.Errorf("%w: %v", ErrConnectionClosed, err)
+	}
+	defer resp.Body.Close()
+
+	if req.Method == "initialize" {
+		if session := resp.Header.Get(sessionHeader); session != "" {
+			t.mu.Lock()
+			t.sessionID = session
+			t.mu.Unlock()
+		}
+	}
+
+	if resp.StatusCode == http.StatusNotFound && httpReq.Header.Get(sessionHeader) != "" {
+		return nil, false, errSessionExpired
+	}
+	if err := statusError(resp); err != nil {
+		return nil, retryableStatus(resp.StatusCode), err
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		result, err := t.readEventStream(resp.Body, idKey(req.ID))
+		return result, false, err
+	}
+
+	var result JSONRPCResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, false, fmt.Errorf("decode response: %w", err)
+	}
+	return &result, false, nil
+}
+
+func (t *httpTransport) reinitialize(ctx context.Context) error {
+	t.mu.Lock()
+	payload := t.initPayload
+	t.sessionID = ""
+	t.mu.Unlock()
+	if payload == nil {
+		return fmt.Errorf("%w: session expired", ErrConnectionClosed)
+	}
+
+	var req JSONRPCRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return err
+	}
+	resp, _, err := t.post(ctx, req, payload)
+	if err != nil {
+		return fmt.Errorf("renew session: %w", err)
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	t.startInitialized(resp)
+	return t.notify(ctx, "notifications/initialized", nil)
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params any) error {
+	if t.isClosed() {
+		return ErrConnectionClosed
+	}
+	payload, err := json.Marshal(JSONRPCRequest{JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json, text/event-stream")
+	t.setHeaders(httpReq)
+
+	resp, err := t.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrConnectionClosed, err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	return statusError(resp)
+}
+
+func (t *httpTransport) setNotificationHandler(handler NotificationHandler) {
+	t.notes.setHandler(handler)
+}
+
+func (t *httpTransport) isClosed() bool {
+	select {
+	case <-t.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	select {
+	case <-t.closed:
+		t.mu.Unlock()
+		return nil
+	default:
+	}
+	close(t.closed)
+	stop := t.stop
+	session := t.sessionID
+	t.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	if session == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.endpoint, nil)
+	if err != nil {
+		return err
+	}
+	t.setHeaders(req)
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (t *httpTransport) setHeaders(req *http.Request) {
+	t.mu.Lock()
+	session, protocol := t.sessionID, t.protocol
+	t.mu.Unlock()
+
+	if t.token != "" {
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	if session != "" {
+		req.Header.Set(sessionHeader, session)
+	}
+	if protocol != "" {
+		req.Header.Set(protocolVersionHeader, protocol)
+	}
+}
+
+func (t *httpTransport) sendCancelled(id any, cause error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = t.notify(ctx, "notifications/cancelled", CancelledParams{RequestID: id, Reason: cause.Error()})
+}
+
+func (t *httpTransport) startInitialized(resp *JSONRPCResponse) {
+	var result InitializeResult
+	if err := json.Unmarshal(resp.Result, &result); err == nil && result.ProtocolVersion != "" {
+		t.mu.Lock()
+		t.protocol = result.ProtocolVersion
+		t.mu.Unlock()
+	}
+
+	t.mu.Lock()
+	if t.streaming || t.isClosed() {
+		t.mu.Unlock()
+		return
+	}
+	t.streaming = true
+	ctx, cancel := context.WithCancel(context.Background())
+	t.stop = cancel
+	t.mu.Unlock()
+
+	go t.listen(ctx)
+}
+
+func (t *httpTransport) listen(ctx context.Context) {
+	backoff := minHTTPBackoff
+	lastEventID := ""
+	for {
+		connected, supported, id := t.streamOnce(ctx, lastEventID)
+		if id != "" {
+			lastEventID = id
+		}
+		if !supported || ctx.Err() != nil {
+			t.mu.Lock()
+			t.streaming = false
+			t.mu.Unlock()
+			return
+		}
+		if connected {
+			backoff = minHTTPBackoff
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		backoff *= 2
+		if backoff > maxHTTPBackoff {
+			backoff = maxHTTPBackoff
+		}
+	}
+}
+
+func (t *httpTransport) streamOnce(ctx context.Context, lastEventID string) (bool, bool, string) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.endpoint, nil)
+	if err != nil {
+		return false, false, ""
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	t.setHeaders(req)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return false, true, ""
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotFound {
+		return false, false, ""
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, true, ""
+	}
+
+	var latest string
+	_ = readEvents(resp.Body, func(id string, data []byte) bool {
+		if id != "" {
+			latest = id
+		}
+		t.dispatch(data)
+		return true
+	})
+	return true, true, latest
+}
+
+func (t *httpTransport) readEventStream(body io.Reader, want string) (*JSONRPCResponse, error) {
+	var result *JSONRPCResponse
+	err := readEvents(body, func(id string, data []byte) bool {
+		var msg incomingMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return true
+		}
+		if msg.Method == "" && idKey(msg.ID) == want {
+			result = &JSONRPCResponse{JSONRPC: msg.JSONRPC, ID: msg.ID, Result: msg.Result, Error: msg.Error}
+			return false
+		}
+		t.dispatch(data)
+		return true
+	})
+	if result != nil {
+		return result, nil
+	}
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return nil, fmt.Errorf("%w: event stream ended before the response: %v", ErrConnectionClosed, err)
+}
+
+func (t *httpTransport) dispatch(data []byte) {
+	var msg incomingMessage
+	if err := json.Unmarshal(data, &msg); err != nil || msg.Method == "" {
+		return
+	}
+	if len(msg.ID) > 0 && string(msg.ID) != "null" {
+		return
+	}
+
+	t.notes.deliver(msg.Method, msg.Params)
+}
+
+func readEvents(body io.Reader, handle func(id string, data []byte) bool) error {
+	reader := bufio.NewReader(body)
+	var data bytes.Buffer
+	var id string
+	for {
+		line, err := reader.ReadString('\n')
+		trimmed := strings.TrimRight(line, "\r\n")
+
+		switch {
+		case trimmed == "" && line != "":
+			if data.Len() > 0 {
+				if !handle(id, bytes.TrimSuffix(data.Bytes(), []byte("\n"))) {
+					return nil
+				}
+			}
+			data.Reset()
+		case strings.HasPrefix(trimmed, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(trimmed, "data:"), " "))
+			data.WriteByte('\n')
+		case strings.HasPrefix(trimmed, "id:"):
+			id = strings.TrimSpace(strings.TrimPrefix(trimmed, "id:"))
+		}
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func statusError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	message := strings.TrimSpace(string(body))
+	if message == "" {
+		message = resp.Status
+	}
+
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: session expired or endpoint not found: %s", ErrConnectionClosed, message)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return fmt.Errorf("vcontext: unauthorized: %s", message)
+	default:
+		return fmt.Errorf("vcontext: http %d: %s", resp.StatusCode, message)
+	}
+}
+
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,326 @@
+package vcontext
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeHTTPServer is a streamable HTTP MCP endpoint that hands out a new
+// session on every initialize and can be told to fail or expire.
+type fakeHTTPServer struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	sessions int
+	session  string
+	calls    map[string]int
+	headers  map[string]http.Header
+	failures map[string]int
+	// stream is sent as the body of the GET listen stream; empty answers
+	// 405 so the client stops listening.
+	stream string
+	// eventStream lists methods answered as an event stream that carries a
+	// progress notification before the response.
+	eventStream map[string]bool
+	// hang lists methods that are never answered.
+	hang map[string]bool
+}
+
+func newFakeHTTPServer(t *testing.T) (*fakeHTTPServer, *httptest.Server) {
+	t.Helper()
+	fake := &fakeHTTPServer{
+		t:           t,
+		calls:       map[string]int{},
+		headers:     map[string]http.Header{},
+		failures:    map[string]int{},
+		eventStream: map[string]bool{},
+		hang:        map[string]bool{},
+	}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func (f *fakeHTTPServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		f.mu.Lock()
+		stream := f.stream
+		f.mu.Unlock()
+		if stream == "" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, stream)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+		return
+	case http.MethodDelete:
+		return
+	}
+
+	var req struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.calls[req.Method]++
+	f.headers[req.Method] = r.Header.Clone()
+	if req.Method == "initialize" {
+		f.sessions++
+		f.session = fmt.Sprintf("session-%d", f.sessions)
+		w.Header().Set(sessionHeader, f.session)
+	} else if r.Header.Get(sessionHeader) != f.session {
+		f.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	failing := f.failures[req.Method] > 0
+	if failing {
+		f.failures[req.Method]--
+	}
+	eventStream := f.eventStream[req.Method]
+	hang := f.hang[req.Method]
+	f.mu.Unlock()
+
+	if hang {
+		<-r.Context().Done()
+		return
+	}
+
+	switch {
+	case len(req.ID) == 0:
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case failing:
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+
+	var result any
+	switch req.Method {
+	case "initialize":
+		result = InitializeResult{ProtocolVersion: ProtocolVersion, ServerInfo: Implementation{Name: "fake", Version: "1"}}
+	case "tools/save_context/invoke":
+		result = SaveContextResult{ID: "saved", CreatedAt: 1}
+	case "tools/search_context/invoke":
+		result = SearchContextResult{Items: []SearchResult{{ID: "found"}}}
+	default:
+		result = struct{}{}
+	}
+	resultJSON, _ := json.Marshal(result)
+	response, _ := json.Marshal(JSONRPCResponse{JSONRPC: "2.0", ID: req.ID, Result: resultJSON})
+
+	if eventStream {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: %s\n\n", `{"jsonrpc":"2.0","method":"notifications/progress","params":{"progress":1}}`)
+		fmt.Fprintf(w, "data: %s\n\n", response)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(response)
+}
+
+func (f *fakeHTTPServer) expire() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.session = ""
+}
+
+func (f *fakeHTTPServer) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+func (f *fakeHTTPServer) header(method string, name string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.headers[method].Get(name)
+}
+
+func dialFake(t *testing.T, url string, opts DialOptions) *Client {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, url, opts)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestHTTPAuthAndSession(t *testing.T) {
+	fake, server := newFakeHTTPServer(t)
+	client := dialFake(t, server.URL, DialOptions{Token: "secret"})
+
+	if _, err := client.SearchContext(context.Background(), SearchContextParams{Query: "q"}); err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	for _, method := range []string{"initialize", "notifications/initialized", "tools/search_context/invoke"} {
+		if got := fake.header(method, "Authorization"); got != "Bearer secret" {
+			t.Fatalf("%s: Authorization = %q, want %q", method, got, "Bearer secret")
+		}
+	}
+	if got := fake.header("initialize", sessionHeader); got != "" {
+		t.Fatalf("initialize sent session %q", got)
+	}
+	if got := fake.header("tools/search_context/invoke", sessionHeader); got != "session-1" {
+		t.Fatalf("search: %s = %q, want session-1", sessionHeader, got)
+	}
+	if got := fake.header("tools/search_context/invoke", protocolVersionHeader); got != ProtocolVersion {
+		t.Fatalf("search: %s = %q, want %s", protocolVersionHeader, got, ProtocolVersion)
+	}
+
+	_, server = newFakeHTTPServer(t)
+	client = dialFake(t, server.URL, DialOptions{})
+	if _, err := client.SearchContext(context.Background(), SearchContextParams{Query: "q"}); err != nil {
+		t.Fatalf("search: %v", err)
+	}
+}
+
+func TestHTTPReinitializeExpiredSession(t *testing.T) {
+	fake, server := newFakeHTTPServer(t)
+	client := dialFake(t, server.URL, DialOptions{})
+
+	fake.expire()
+	// Saves are not retried, but an expired session is renewed for them
+	// too: the server never saw the first attempt.
+	saved, err := client.SaveContext(context.Background(), SaveContextParams{Content: "note"})
+	if err != nil {
+		t.Fatalf("save after the session expired: %v", err)
+	}
+	if saved.ID != "saved" {
+		t.Fatalf("save = %+v", saved)
+	}
+	if got := fake.count("initialize"); got != 2 {
+		t.Fatalf("initialize sent %d times, want 2", got)
+	}
+	if got := fake.count("notifications/initialized"); got != 2 {
+		t.Fatalf("notifications/initialized sent %d times, want 2", got)
+	}
+	if got := fake.header("tools/save_context/invoke", sessionHeader); got != "session-2" {
+		t.Fatalf("save: %s = %q, want session-2", sessionHeader, got)
+	}
+}
+
+func TestHTTPEventStreamNotifications(t *testing.T) {
+	fake, server := newFakeHTTPServer(t)
+	fake.eventStream["tools/search_context/invoke"] = true
+	fake.stream = "id: 7\ndata: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/message\",\"params\":{\"data\":\"hello\"}}\n\n"
+
+	notes := make(chan string, 8)
+	client := &Client{conn: newHTTPTransport(server.URL, DialOptions{})}
+	t.Cleanup(func() { _ = client.Close() })
+	client.SetNotificationHandler(func(method string, params json.RawMessage) {
+		notes <- method
+	})
+	if _, err := client.Initialize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.SearchContext(context.Background(), SearchContextParams{Query: "q"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(result.Items) != 1 || result.Items[0].ID != "found" {
+		t.Fatalf("search = %+v", result)
+	}
+
+	want := map[string]bool{"notifications/progress": true, "notifications/message": true}
+	timeout := time.After(5 * time.Second)
+	for len(want) > 0 {
+		select {
+		case method := <-notes:
+			delete(want, method)
+		case <-timeout:
+			t.Fatalf("notifications not delivered: %v", want)
+		}
+	}
+}
+
+func TestHTTPRetries(t *testing.T) {
+	fake, server := newFakeHTTPServer(t)
+	client := dialFake(t, server.URL, DialOptions{MaxRetries: 2})
+
+	fake.failures["tools/search_context/invoke"] = 2
+	start := time.Now()
+	if _, err := client.SearchContext(context.Background(), SearchContextParams{Query: "q"}); err != nil {
+		t.Fatalf("search after two failures: %v", err)
+	}
+	if got := fake.count("tools/search_context/invoke"); got != 3 {
+		t.Fatalf("search sent %d times, want 3", got)
+	}
+	if elapsed, want := time.Since(start), minHTTPBackoff+2*minHTTPBackoff; elapsed < want {
+		t.Fatalf("retried after %v, want a backoff of at least %v", elapsed, want)
+	}
+
+	fake.failures["tools/search_context/invoke"] = 3
+	if _, err := client.SearchContext(context.Background(), SearchContextParams{Query: "q"}); err == nil {
+		t.Fatal("search succeeded after exhausting its retries")
+	}
+	if got := fake.count("tools/search_context/invoke"); got != 6 {
+		t.Fatalf("search sent %d times, want 6", got)
+	}
+
+	fake.failures["tools/save_context/invoke"] = 1
+	if _, err := client.SaveContext(context.Background(), SaveContextParams{Content: "note"}); err == nil {
+		t.Fatal("save succeeded, want the 503 reported")
+	}
+	if got := fake.count("tools/save_context/invoke"); got != 1 {
+		t.Fatalf("save sent %d times, want 1: writes must not be retried", got)
+	}
+}
+
+func TestHTTPClosed(t *testing.T) {
+	fake, server := newFakeHTTPServer(t)
+	client := dialFake(t, server.URL, DialOptions{})
+
+	fake.hang["tools/search_context/invoke"] = true
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.SearchContext(context.Background(), SearchContextParams{Query: "q"})
+		done <- err
+	}()
+	for fake.count("tools/search_context/invoke") == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// An expired session must not be renewed once the client is closed.
+	fake.expire()
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrConnectionClosed) {
+			t.Fatalf("search in flight at close = %v, want %v", err, ErrConnectionClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close did not cancel the search in flight")
+	}
+
+	if _, err := client.GetContext(context.Background(), GetContextParams{ID: "x"}); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("get after close = %v, want %v", err, ErrConnectionClosed)
+	}
+	if got := fake.count("tools/get_context/invoke"); got != 0 {
+		t.Fatalf("get sent %d times after close", got)
+	}
+	if got := fake.count("initialize"); got != 1 {
+		t.Fatalf("initialize sent %d times, want 1", got)
+	}
+}
@@ -0,0 +1,336 @@
+package vcontext
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	defaultShutdownTimeout = 5 * time.Second
+	minRestartBackoff      = 200 * time.Millisecond
+	maxRestartBackoff      = 30 * time.Second
+	stableRunDuration      = time.Minute
+	maxStderrLine          = 64 * 1024
+)
+
+type Options struct {
+	BinaryPath      string
+	DBPath          string
+	Args            []string
+	Env             []string
+	Logger          *slog.Logger
+	ShutdownTimeout time.Duration
+	DisableRestart  bool
+}
+
+type process struct {
+	opts   Options
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	conn    *streamConn
+	ready   chan struct{}
+	exited  chan struct{}
+	onNote  NotificationHandler
+	closing bool
+	backoff time.Duration
+}
+
+func Spawn(ctx context.Context, opts Options) (*Client, error) {
+	if opts.BinaryPath == "" {
+		path, err := exec.LookPath("vcontext")
+		if err != nil {
+			return nil, fmt.Errorf("find vcontext binary: %w", err)
+		}
+		opts.BinaryPath = path
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	p := &process{
+		opts:   opts,
+		logger: logger,
+		ready:  make(chan struct{}),
+	}
+	if err := p.start(ctx); err != nil {
+		return nil, err
+	}
+	return &Client{conn: p}, nil
+}
+
+func (p *process) start(ctx context.Context) error {
+	args := append([]string{}, p.opts.Args...)
+	if p.opts.DBPath != "" {
+		args = append(args, "-db", p.opts.DBPath)
+	}
+
+	cmd := exec.Command(p.opts.BinaryPath, args...)
+	cmd.Env = append(os.Environ(), p.opts.Env...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return fmt.Errorf("spawn vcontext: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("spawn vcontext: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("spawn vcontext: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("spawn vcontext: %w", err)
+	}
+
+	pid := cmd.Process.Pid
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		p.forwardStderr(stderr, pid)
+	}()
+
+	conn := newStreamConn(stdout, stdin)
+	p.mu.Lock()
+	conn.setNotificationHandler(p.onNote)
+	p.mu.Unlock()
+
+	exited := make(chan struct{})
+	waitErr := make(chan error, 1)
+	go func() {
+		// Wait closes the pipes, so it must wait for both readers to see
+		// EOF; otherwise the last response and the stderr explaining a
+		// crash can be lost.
+		<-conn.readDone
+		<-stderrDone
+		waitErr <- cmd.Wait()
+		close(exited)
+	}()
+
+	info, err := (&Client{conn: conn}).Initialize(ctx)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		<-exited
+		return fmt.Errorf("initialize vcontext: %w", err)
+	}
+
+	p.mu.Lock()
+	if p.closing {
+		p.mu.Unlock()
+		_ = cmd.Process.Kill()
+		<-exited
+		return ErrConnectionClosed
+	}
+	p.cmd = cmd
+	p.stdin = stdin
+	p.conn = conn
+	p.exited = exited
+	close(p.ready)
+	p.mu.Unlock()
+
+	p.logger.Info("vcontext server started", "pid", pid, "version", info.ServerInfo.Version)
+	go p.supervise(time.Now(), exited, waitErr)
+	return nil
+}
+
+func (p *process) supervise(startedAt time.Time, exited chan struct{}, waitErr chan error) {
+	<-exited
+	err := <-waitErr
+
+	p.mu.Lock()
+	if p.closing {
+		p.mu.Unlock()
+		return
+	}
+	p.conn = nil
+	p.ready = make(chan struct{})
+	p.mu.Unlock()
+
+	p.logger.Warn("vcontext server exited", "err", err)
+	if p.opts.DisableRestart {
+		p.shutdown(errors.New("vcontext server exited"))
+		return
+	}
+
+	if time.Since(startedAt) >= stableRunDuration {
+		p.resetBackoff()
+	}
+
+	backoff := p.nextBackoff()
+	for {
+		time.Sleep(backoff)
+
+		p.mu.Lock()
+		closing := p.closing
+		p.mu.Unlock()
+		if closing {
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := p.start(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		p.logger.Error("restart vcontext server", "err", err, "retry_in", backoff)
+		backoff = p.nextBackoff()
+	}
+}
+
+func (p *process) nextBackoff() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.backoff == 0 {
+		p.backoff = minRestartBackoff
+	} else {
+		p.backoff *= 2
+		if p.backoff > maxRestartBackoff {
+			p.backoff = maxRestartBackoff
+		}
+	}
+	return p.backoff
+}
+
+func (p *process) resetBackoff() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.backoff = 0
+}
+
+// forwardStderr logs the stderr of the server line by line until it is
+// closed. Overlong lines are truncated rather than ending the loop, so the
+// pipe is always drained and the server never blocks writing to it.
+func (p *process) forwardStderr(stderr io.Reader, pid int) {
+	reader := bufio.NewReaderSize(stderr, maxStderrLine)
+	for {
+		line, truncated, err := readStderrLine(reader)
+		if len(line) > 0 || truncated {
+			if truncated {
+				line += " [truncated]"
+			}
+			p.logger.Info(line, "pid", pid)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// readStderrLine reads one line without its line ending, keeping at most
+// the first maxStderrLine bytes.
+func readStderrLine(reader *bufio.Reader) (string, bool, error) {
+	chunk, err := reader.ReadSlice('\n')
+	line := string(bytes.TrimRight(chunk, "\r\n"))
+	truncated := false
+	for errors.Is(err, bufio.ErrBufferFull) {
+		truncated = true
+		_, err = reader.ReadSlice('\n')
+	}
+	return line, truncated, err
+}
+
+func (p *process) current(ctx context.Context) (*streamConn, error) {
+	for {
+		p.mu.Lock()
+		conn, ready, closing := p.conn, p.ready, p.closing
+		p.mu.Unlock()
+
+		if closing {
+			return nil, ErrConnectionClosed
+		}
+		if conn != nil {
+			return conn, nil
+		}
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, contextError(ctx)
+		}
+	}
+}
+
+func (p *process) roundTrip(ctx context.Context, req JSONRPCRequest) (*JSONRPCResponse, error) {
+	conn, err := p.current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn.roundTrip(ctx, req)
+}
+
+func (p *process) notify(ctx context.Context, method string, params any) error {
+	conn, err := p.current(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.notify(ctx, method, params)
+}
+
+func (p *process) setNotificationHandler(handler NotificationHandler) {
+	p.mu.Lock()
+	p.onNote = handler
+	conn := p.conn
+	p.mu.Unlock()
+	if conn != nil {
+		conn.setNotificationHandler(handler)
+	}
+}
+
+func (p *process) close() error {
+	p.mu.Lock()
+	if p.closing {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closing = true
+	cmd, stdin, conn, exited := p.cmd, p.stdin, p.conn, p.exited
+	p.mu.Unlock()
+
+	if conn != nil {
+		conn.fail(ErrConnectionClosed)
+	}
+	if cmd == nil {
+		return nil
+	}
+
+	_ = stdin.Close()
+	select {
+	case <-exited:
+		return nil
+	case <-time.After(p.opts.ShutdownTimeout):
+	}
+
+	p.logger.Warn("vcontext server did not exit, killing it", "pid", cmd.Process.Pid)
+	if err := cmd.Process.Kill(); err != nil && !errors.Is(err, os.ErrProcessDone) {
+		return fmt.Errorf("kill vcontext: %w", err)
+	}
+	<-exited
+	return nil
+}
+
+func (p *process) shutdown(err error) {
+	p.mu.Lock()
+	p.closing = true
+	p.mu.Unlock()
+	p.logger.Error("vcontext server will not be restarted", "err", err)
+}
@@ -0,0 +1,125 @@
+package vcontext
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const crashingServerEnv = "VCONTEXT_TEST_CRASHING_SERVER"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(crashingServerEnv) == "1" {
+		runCrashingServer()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// runCrashingServer answers initialize, then answers get_context, explains
+// itself on stderr and exits at once.
+func runCrashingServer() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &req) != nil || len(req.ID) == 0 {
+			continue
+		}
+		switch req.Method {
+		case "initialize":
+			fmt.Printf(`{"jsonrpc":"2.0","id":%s,"result":{"protocolVersion":%q,"serverInfo":{"name":"crash","version":"1"}}}`+"\n", req.ID, ProtocolVersion)
+		case "tools/get_context/invoke":
+			for i := 0; i < 1000; i++ {
+				fmt.Fprintf(os.Stderr, "dying %d\n", i)
+			}
+			fmt.Printf(`{"jsonrpc":"2.0","id":%s,"result":{"id":"last","content":"last words"}}`+"\n", req.ID)
+			os.Exit(3)
+		}
+	}
+}
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// The last response and stderr of a server that exits right away must
+// still be read before the process is reaped.
+func TestSpawnReadsOutputOfExitedServer(t *testing.T) {
+	var logs syncBuffer
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := Spawn(ctx, Options{
+		BinaryPath:     os.Args[0],
+		Env:            []string{crashingServerEnv + "=1"},
+		Logger:         slog.New(slog.NewTextHandler(&logs, nil)),
+		DisableRestart: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	item, err := client.GetContext(ctx, GetContextParams{ID: "last"})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if item.Content != "last words" {
+		t.Fatalf("get = %+v", item)
+	}
+
+	for !strings.Contains(logs.String(), "vcontext server exited") {
+		if ctx.Err() != nil {
+			t.Fatal("server exit not noticed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := strings.Count(logs.String(), "msg=\"dying "); got != 1000 {
+		t.Fatalf("logged %d stderr lines of the exited server, want 1000", got)
+	}
+}
+
+// A line longer than the buffer must not stop stderr from being drained.
+func TestForwardStderrLongLine(t *testing.T) {
+	var logs bytes.Buffer
+	p := &process{logger: slog.New(slog.NewTextHandler(&logs, nil))}
+
+	stderr := "first\n" + strings.Repeat("x", 3*maxStderrLine) + "\r\nlast\n" + strings.Repeat("y", 1024*1024)
+	p.forwardStderr(strings.NewReader(stderr), 42)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("logged %d lines, want 4:\n%.500s", len(lines), logs.String())
+	}
+	if !strings.Contains(lines[0], "msg=first") || !strings.Contains(lines[2], "msg=last") {
+		t.Fatalf("lines lost around the long one:\n%.500s", logs.String())
+	}
+	for _, i := range []int{1, 3} {
+		if !strings.Contains(lines[i], "[truncated]") || len(lines[i]) > maxStderrLine+100 {
+			t.Fatalf("line %d not truncated: %.200s", i, lines[i])
+		}
+	}
+}
@@ -2,6 +2,8 @@ package vcontext
 
 import "encoding/json"
 
+const ProtocolVersion = "2025-06-18"
+
 type JSONRPCRequest struct {
 	JSONRPC string `json:"jsonrpc"`
 	ID      any    `json:"id,omitempty"`
@@ -16,18 +18,10 @@ type JSONRPCResponse struct {
 	Error   *RPCError       `json:"error,omitempty"`
 }
 
-type RPCError struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
-}
-
-func (e *RPCError) Error() string {
-	return e.Message
-}
-
 type ContextItem struct {
 	ID         string    `json:"id"`
 	CreatedAt  int64     `json:"created_at"`
+	Namespace  *string   `json:"namespace,omitempty"`
 	Source     *string   `json:"source,omitempty"`
 	ThreadID   *string   `json:"thread_id,omitempty"`
 	Role       *string   `json:"role,omitempty"`
@@ -40,6 +34,7 @@ type ContextItem struct {
 type SearchResult struct {
 	ID         string  `json:"id"`
 	Title      *string `json:"title,omitempty"`
+	Namespace  *string `json:"namespace,omitempty"`
 	Source     *string `json:"source,omitempty"`
 	ThreadID   *string `json:"thread_id,omitempty"`
 	CreatedAt  int64   `json:"created_at"`
@@ -48,6 +43,7 @@ type SearchResult struct {
 }
 
 type SaveContextParams struct {
+	Namespace  *string   `json:"namespace,omitempty"`
 	Source     *string   `json:"source,omitempty"`
 	ThreadID   *string   `json:"thread_id,omitempty"`
 	Role       *string   `json:"role,omitempty"`
@@ -65,6 +61,7 @@ type SaveContextResult struct {
 type SearchContextParams struct {
 	Query         string  `json:"query"`
 	TopK          *int    `json:"top_k,omitempty"`
+	Namespace     *string `json:"namespace,omitempty"`
 	ThreadID      *string `json:"thread_id,omitempty"`
 	MinImportance *int    `json:"min_importance,omitempty"`
 }
@@ -76,3 +73,83 @@ type SearchContextResult struct {
 type GetContextParams struct {
 	ID string `json:"id"`
 }
+
+type BulkSaveContextParams struct {
+	Items []SaveContextParams `json:"items"`
+}
+
+type BulkSaveContextResult struct {
+	Items []SaveContextResult `json:"items"`
+}
+
+type ReindexResult struct {
+	Indexed int `json:"indexed"`
+}
+
+type MemoryStatsParams struct {
+	Namespace *string `json:"namespace,omitempty"`
+	Top       *int    `json:"top,omitempty"`
+	Days      *int    `json:"days,omitempty"`
+}
+
+type MemoryStatsResult struct {
+	Items        int   `json:"items"`
+	ContentBytes int64 `json:"content_bytes"`
+	FirstAt      int64 `json:"first_at,omitempty"`
+	LastAt       int64 `json:"last_at,omitempty"`
+
+	Namespaces []ValueCount      `json:"namespaces"`
+	Threads    []ValueCount      `json:"threads"`
+	Sources    []ValueCount      `json:"sources"`
+	Roles      []ValueCount      `json:"roles"`
+	Tags       []ValueCount      `json:"tags"`
+	Importance []ImportanceCount `json:"importance"`
+	PerDay     []DayCount        `json:"per_day"`
+	Largest    []ItemSize        `json:"largest"`
+
+	DatabaseBytes int64 `json:"database_bytes"`
+	IndexBytes    int64 `json:"index_bytes"`
+}
+
+type ValueCount struct {
+	Value *string `json:"value"`
+	Items int     `json:"items"`
+	Bytes int64   `json:"bytes"`
+}
+
+type ImportanceCount struct {
+	Importance int `json:"importance"`
+	Items      int `json:"items"`
+}
+
+type DayCount struct {
+	Day   string `json:"day"`
+	Items int    `json:"items"`
+}
+
+type ItemSize struct {
+	ID        string  `json:"id"`
+	Title     *string `json:"title,omitempty"`
+	Namespace *string `json:"namespace,omitempty"`
+	ThreadID  *string `json:"thread_id,omitempty"`
+	CreatedAt int64   `json:"created_at"`
+	Bytes     int64   `json:"bytes"`
+}
+
+type Implementation struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type InitializeParams struct {
+	ProtocolVersion string         `json:"protocolVersion"`
+	Capabilities    map[string]any `json:"capabilities"`
+	ClientInfo      Implementation `json:"clientInfo"`
+}
+
+type InitializeResult struct {
+	ProtocolVersion string          `json:"protocolVersion"`
+	Capabilities    json.RawMessage `json:"capabilities,omitempty"`
+	ServerInfo      Implementation  `json:"serverInfo"`
+	Instructions    string          `json:"instructions,omitempty"`
+}
@@ -0,0 +1,670 @@
+package vcontexttest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vcontext/pkg/vcontext"
+)
+
+const (
+	MethodInitialize      = "initialize"
+	MethodPing            = "ping"
+	MethodSaveContext     = "tools/save_context/invoke"
+	MethodSearchContext   = "tools/search_context/invoke"
+	MethodGetContext      = "tools/get_context/invoke"
+	MethodBulkSaveContext = "tools/bulk_save_context/invoke"
+	MethodReindex         = "tools/reindex/invoke"
+	MethodMemoryStats     = "tools/memory_stats/invoke"
+	MethodCancelled       = "notifications/cancelled"
+)
+
+type Call struct {
+	Method string
+	ID     json.RawMessage
+	Params json.RawMessage
+	At     time.Time
+}
+
+type Server struct {
+	Now func() time.Time
+
+	mu       sync.Mutex
+	items    []vcontext.ContextItem
+	nextID   int
+	calls    []Call
+	errs     map[string]*vcontext.RPCError
+	once     map[string][]*vcontext.RPCError
+	latency  map[string]time.Duration
+	inflight map[string]context.CancelFunc
+	conns    []*conn
+}
+
+type conn struct {
+	writeMu sync.Mutex
+	writer  io.Writer
+}
+
+func NewServer() *Server {
+	return &Server{
+		Now:      time.Now,
+		errs:     make(map[string]*vcontext.RPCError),
+		once:     make(map[string][]*vcontext.RPCError),
+		latency:  make(map[string]time.Duration),
+		inflight: make(map[string]context.CancelFunc),
+	}
+}
+
+func (s *Server) Client() *vcontext.Client {
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+	go func() {
+		err := s.Serve(serverReader, serverWriter)
+		_ = serverWriter.CloseWithError(err)
+	}()
+	return vcontext.NewClient(clientReader, clientWriter)
+}
+
+func (s *Server) Serve(r io.Reader, w io.Writer) error {
+	c := &conn{writer: w}
+	s.mu.Lock()
+	s.conns = append(s.conns, c)
+	s.mu.Unlock()
+	defer s.removeConn(c)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			// Calls are recorded in the order they arrive; only their
+			// handling runs concurrently.
+			if call, ok := s.record(c, line); ok {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.handle(c, call)
+				}()
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (s *Server) Seed(items ...vcontext.ContextItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range items {
+		if item.ID == "" {
+			item.ID = s.newIDLocked()
+		}
+		if item.CreatedAt == 0 {
+			item.CreatedAt = s.Now().Unix()
+		}
+		if item.Importance == 0 {
+			item.Importance = 3
+		}
+		s.items = append(s.items, item)
+	}
+}
+
+func (s *Server) Items() []vcontext.ContextItem {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]vcontext.ContextItem(nil), s.items...)
+}
+
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+func (s *Server) CallsTo(method string) []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var calls []Call
+	for _, call := range s.calls {
+		if call.Method == method {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+func (s *Server) SetError(method string, err *vcontext.RPCError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		delete(s.errs, method)
+		return
+	}
+	s.errs[method] = err
+}
+
+func (s *Server) FailNext(method string, err *vcontext.RPCError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.once[method] = append(s.once[method], err)
+}
+
+func (s *Server) SetLatency(method string, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency[method] = latency
+}
+
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.items = nil
+	s.calls = nil
+	s.nextID = 0
+	s.errs = make(map[string]*vcontext.RPCError)
+	s.once = make(map[string][]*vcontext.RPCError)
+	s.latency = make(map[string]time.Duration)
+}
+
+func (s *Server) Notify(method string, params any) error {
+	s.mu.Lock()
+	conns := append([]*conn(nil), s.conns...)
+	s.mu.Unlock()
+
+	var errs []error
+	for _, c := range conns {
+		errs = append(errs, c.write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params}))
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) removeConn(c *conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.conns {
+		if existing == c {
+			s.conns = append(s.conns[:i], s.conns[i+1:]...)
+			return
+		}
+	}
+}
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string             `json:"jsonrpc"`
+	ID      json.RawMessage    `json:"id"`
+	Result  any                `json:"result,omitempty"`
+	Error   *vcontext.RPCError `json:"error,omitempty"`
+}
+
+// pendingCall is a recorded request waiting to be handled.
+type pendingCall struct {
+	req      request
+	ctx      context.Context
+	cancel   context.CancelFunc
+	latency  time.Duration
+	injected *vcontext.RPCError
+}
+
+// record parses a message, records the call and takes its injected error
+// and latency. It answers a message that does not parse itself.
+func (s *Server) record(c *conn, line []byte) (pendingCall, bool) {
+	var req request
+	if err := json.Unmarshal(line, &req); err != nil {
+		_ = c.write(response{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &vcontext.RPCError{Code: vcontext.CodeParse, Message: "parse error"}})
+		return pendingCall{}, false
+	}
+
+	call := pendingCall{req: req}
+	call.ctx, call.cancel = context.WithCancel(context.Background())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, Call{Method: req.Method, ID: req.ID, Params: req.Params, At: s.Now()})
+	call.latency = s.latency[req.Method]
+	call.injected = s.errs[req.Method]
+	if queued := s.once[req.Method]; len(queued) > 0 {
+		call.injected = queued[0]
+		s.once[req.Method] = queued[1:]
+	}
+	if expectsResponse(req) {
+		s.inflight[string(req.ID)] = call.cancel
+	}
+	return call, true
+}
+
+func expectsResponse(req request) bool {
+	return len(req.ID) > 0 && string(req.ID) != "null"
+}
+
+func (s *Server) handle(c *conn, call pendingCall) {
+	req, ctx, latency, injected := call.req, call.ctx, call.latency, call.injected
+	defer call.cancel()
+
+	isRequest := expectsResponse(req)
+	if isRequest {
+		defer func() {
+			s.mu.Lock()
+			delete(s.inflight, string(req.ID))
+			s.mu.Unlock()
+		}()
+	}
+
+	if req.Method == MethodCancelled {
+		s.cancel(req.Params)
+		return
+	}
+
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+
+	if !isRequest {
+		return
+	}
+
+	resp := response{JSONRPC: "2.0", ID: req.ID}
+	if injected != nil {
+		resp.Error = injected
+	} else {
+		resp.Result, resp.Error = s.dispatch(req.Method, req.Params)
+	}
+	_ = c.write(resp)
+}
+
+func (s *Server) cancel(params json.RawMessage) {
+	var input struct {
+		RequestID json.RawMessage `json:"requestId"`
+	}
+	if err := json.Unmarshal(params, &input); err != nil {
+		return
+	}
+	s.mu.Lock()
+	cancel := s.inflight[strings.TrimSpace(string(input.RequestID))]
+	s.mu.Unlock()
+	if cancel != nil {
+		cancel()
+	}
+}
+
+func (s *Server) dispatch(method string, params json.RawMessage) (any, *vcontext.RPCError) {
+	switch method {
+	case MethodInitialize:
+		return vcontext.InitializeResult{
+			ProtocolVersion: vcontext.ProtocolVersion,
+			Capabilities:    json.RawMessage(`{"tools":{}}`),
+			ServerInfo:      vcontext.Implementation{Name: "vcontexttest", Version: "test"},
+		}, nil
+	case MethodPing:
+		return struct{}{}, nil
+	case MethodSaveContext:
+		var input vcontext.SaveContextParams
+		if rpcErr := decode(params, &input); rpcErr != nil {
+			return nil, rpcErr
+		}
+		results, rpcErr := s.save([]vcontext.SaveContextParams{input}, "")
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return results[0], nil
+	case MethodBulkSaveContext:
+		var input vcontext.BulkSaveContextParams
+		if rpcErr := decode(params, &input); rpcErr != nil {
+			return nil, rpcErr
+		}
+		if len(input.Items) == 0 {
+			return nil, invalidField("items", "required", "items must contain at least one item")
+		}
+		results, rpcErr := s.save(input.Items, "items[%d].")
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return vcontext.BulkSaveContextResult{Items: results}, nil
+	case MethodSearchContext:
+		var input vcontext.SearchContextParams
+		if rpcErr := decode(params, &input); rpcErr != nil {
+			return nil, rpcErr
+		}
+		return s.search(input)
+	case MethodGetContext:
+		var input vcontext.GetContextParams
+		if rpcErr := decode(params, &input); rpcErr != nil {
+			return nil, rpcErr
+		}
+		return s.get(input)
+	case MethodReindex:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return vcontext.ReindexResult{Indexed: len(s.items)}, nil
+	case MethodMemoryStats:
+		var input vcontext.MemoryStatsParams
+		if trimmed := strings.TrimSpace(string(params)); trimmed != "" && trimmed != "null" {
+			if rpcErr := decode(params, &input); rpcErr != nil {
+				return nil, rpcErr
+			}
+		}
+		return s.stats(input), nil
+	default:
+		return nil, &vcontext.RPCError{Code: vcontext.CodeMethodNotFound, Message: "method not found"}
+	}
+}
+
+// stats computes the breakdowns of the real server over the stored items;
+// the database and index sizes are always zero.
+func (s *Server) stats(input vcontext.MemoryStatsParams) vcontext.MemoryStatsResult {
+	top := 10
+	if input.Top != nil {
+		top = min(max(*input.Top, 1), 100)
+	}
+	days := 30
+	if input.Days != nil {
+		days = min(max(*input.Days, 1), 366)
+	}
+	today := s.Now().UTC().Truncate(24 * time.Hour)
+	since := today.AddDate(0, 0, 1-days).Unix()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := vcontext.MemoryStatsResult{}
+	breakdowns := map[string]map[string]*vcontext.ValueCount{}
+	count := func(name string, value *string, bytes int64) {
+		if breakdowns[name] == nil {
+			breakdowns[name] = map[string]*vcontext.ValueCount{}
+		}
+		key := "\x00"
+		if value != nil {
+			key = *value
+		}
+		entry := breakdowns[name][key]
+		if entry == nil {
+			entry = &vcontext.ValueCount{Value: value}
+			breakdowns[name][key] = entry
+		}
+		entry.Items++
+		entry.Bytes += bytes
+	}
+	importance := map[int]int{}
+	perDay := map[string]int{}
+
+	var items []vcontext.ContextItem
+	for _, item := range s.items {
+		if !matchesOptional(input.Namespace, item.Namespace) {
+			continue
+		}
+		items = append(items, item)
+		bytes := int64(len(item.Content))
+		result.Items++
+		result.ContentBytes += bytes
+		if result.FirstAt == 0 || item.CreatedAt < result.FirstAt {
+			result.FirstAt = item.CreatedAt
+		}
+		if item.CreatedAt > result.LastAt {
+			result.LastAt = item.CreatedAt
+		}
+		count("namespaces", item.Namespace, bytes)
+		count("threads", item.ThreadID, bytes)
+		count("sources", item.Source, bytes)
+		count("roles", item.Role, bytes)
+		if item.Tags != nil {
+			for _, tag := range *item.Tags {
+				tag := tag
+				count("tags", &tag, bytes)
+			}
+		}
+		importance[item.Importance]++
+		if item.CreatedAt >= since {
+			perDay[time.Unix(item.CreatedAt, 0).UTC().Format("2006-01-02")]++
+		}
+	}
+
+	ranked := func(name string) []vcontext.ValueCount {
+		counts := make([]vcontext.ValueCount, 0, len(breakdowns[name]))
+		for _, entry := range breakdowns[name] {
+			counts = append(counts, *entry)
+		}
+		sort.Slice(counts, func(i, j int) bool {
+			if counts[i].Items != counts[j].Items {
+				return counts[i].Items > counts[j].Items
+			}
+			return counts[i].Value != nil && (counts[j].Value == nil || *counts[i].Value < *counts[j].Value)
+		})
+		if len(counts) > top {
+			counts = counts[:top]
+		}
+		return counts
+	}
+	result.Namespaces = ranked("namespaces")
+	result.Threads = ranked("threads")
+	result.Sources = ranked("sources")
+	result.Roles = ranked("roles")
+	result.Tags = ranked("tags")
+
+	result.Importance = make([]vcontext.ImportanceCount, 0, len(importance))
+	for level, n := range importance {
+		result.Importance = append(result.Importance, vcontext.ImportanceCount{Importance: level, Items: n})
+	}
+	sort.Slice(result.Importance, func(i, j int) bool {
+		return result.Importance[i].Importance < result.Importance[j].Importance
+	})
+	result.PerDay = make([]vcontext.DayCount, 0, len(perDay))
+	for day, n := range perDay {
+		result.PerDay = append(result.PerDay, vcontext.DayCount{Day: day, Items: n})
+	}
+	sort.Slice(result.PerDay, func(i, j int) bool {
+		return result.PerDay[i].Day < result.PerDay[j].Day
+	})
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return len(items[i].Content) > len(items[j].Content)
+	})
+	if len(items) > top {
+		items = items[:top]
+	}
+	result.Largest = make([]vcontext.ItemSize, 0, len(items))
+	for _, item := range items {
+		result.Largest = append(result.Largest, vcontext.ItemSize{
+			ID:        item.ID,
+			Title:     item.Title,
+			Namespace: item.Namespace,
+			ThreadID:  item.ThreadID,
+			CreatedAt: item.CreatedAt,
+			Bytes:     int64(len(item.Content)),
+		})
+	}
+	return result
+}
+
+func (s *Server) save(inputs []vcontext.SaveContextParams, fieldFormat string) ([]vcontext.SaveContextResult, *vcontext.RPCError) {
+	for i, input := range inputs {
+		if strings.TrimSpace(input.Content) == "" {
+			prefix := ""
+			if fieldFormat != "" {
+				prefix = fmt.Sprintf(fieldFormat, i)
+			}
+			return nil, invalidField(prefix+"content", "required", prefix+"content is required")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]vcontext.SaveContextResult, 0, len(inputs))
+	for _, input := range inputs {
+		importance := 3
+		if input.Importance != nil {
+			importance = *input.Importance
+		}
+		item := vcontext.ContextItem{
+			ID:         s.newIDLocked(),
+			CreatedAt:  s.Now().Unix(),
+			Namespace:  input.Namespace,
+			Source:     input.Source,
+			ThreadID:   input.ThreadID,
+			Role:       input.Role,
+			Title:      input.Title,
+			Content:    input.Content,
+			Tags:       input.Tags,
+			Importance: importance,
+		}
+		s.items = append(s.items, item)
+		results = append(results, vcontext.SaveContextResult{ID: item.ID, CreatedAt: item.CreatedAt})
+	}
+	return results, nil
+}
+
+func (s *Server) search(input vcontext.SearchContextParams) (any, *vcontext.RPCError) {
+	query := strings.ToLower(strings.TrimSpace(input.Query))
+	if query == "" {
+		return nil, invalidField("query", "required", "query is required")
+	}
+
+	topK := 5
+	if input.TopK != nil {
+		topK = *input.TopK
+	}
+	if topK < 1 {
+		topK = 1
+	}
+	if topK > 50 {
+		topK = 50
+	}
+	minImportance := 1
+	if input.MinImportance != nil && *input.MinImportance > 1 {
+		minImportance = *input.MinImportance
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	matches := make([]vcontext.ContextItem, 0)
+	for _, item := range s.items {
+		if item.Importance < minImportance {
+			continue
+		}
+		if !matchesOptional(input.Namespace, item.Namespace) || !matchesOptional(input.ThreadID, item.ThreadID) {
+			continue
+		}
+		title := ""
+		if item.Title != nil {
+			title = *item.Title
+		}
+		if strings.Contains(strings.ToLower(item.Content), query) || strings.Contains(strings.ToLower(title), query) {
+			matches = append(matches, item)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Importance > matches[j].Importance
+	})
+	if len(matches) > topK {
+		matches = matches[:topK]
+	}
+
+	results := make([]vcontext.SearchResult, 0, len(matches))
+	for _, item := range matches {
+		results = append(results, vcontext.SearchResult{
+			ID:         item.ID,
+			Title:      item.Title,
+			Namespace:  item.Namespace,
+			Source:     item.Source,
+			ThreadID:   item.ThreadID,
+			CreatedAt:  item.CreatedAt,
+			Importance: item.Importance,
+			Snippet:    snippet(item.Content),
+		})
+	}
+	return vcontext.SearchContextResult{Items: results}, nil
+}
+
+func (s *Server) get(input vcontext.GetContextParams) (any, *vcontext.RPCError) {
+	id := strings.TrimSpace(input.ID)
+	if id == "" {
+		return nil, invalidField("id", "required", "id is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range s.items {
+		if item.ID == id {
+			return item, nil
+		}
+	}
+	return nil, &vcontext.RPCError{
+		Code:    vcontext.CodeNotFound,
+		Message: "no memory exists with this id",
+		Data:    json.RawMessage(`{"field":"id","constraint":"exists"}`),
+	}
+}
+
+func (s *Server) newIDLocked() string {
+	s.nextID++
+	return fmt.Sprintf("item-%d", s.nextID)
+}
+
+func (c *conn) write(msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_, err = c.writer.Write(data)
+	return err
+}
+
+func decode(params json.RawMessage, target any) *vcontext.RPCError {
+	trimmed := strings.TrimSpace(string(params))
+	if trimmed == "" || trimmed == "null" {
+		return &vcontext.RPCError{Code: vcontext.CodeInvalidParams, Message: "params are required"}
+	}
+	if err := json.Unmarshal(params, target); err != nil {
+		return &vcontext.RPCError{Code: vcontext.CodeInvalidParams, Message: "invalid params"}
+	}
+	return nil
+}
+
+func invalidField(field string, constraint string, message string) *vcontext.RPCError {
+	data, _ := json.Marshal(vcontext.ErrorData{Field: field, Constraint: constraint})
+	return &vcontext.RPCError{Code: vcontext.CodeInvalidParams, Message: message, Data: data}
+}
+
+func snippet(content string) string {
+	runes := []rune(strings.TrimSpace(content))
+	if len(runes) > 160 {
+		return string(runes[:160]) + "..."
+	}
+	return string(runes)
+}
+
+func matchesOptional(want *string, got *string) bool {
+	return want == nil || got != nil && *got == *want
+}
@@ -0,0 +1,181 @@
+package vcontexttest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"vcontext/pkg/vcontext"
+)
+
+func newClient(t *testing.T, srv *Server) *vcontext.Client {
+	t.Helper()
+	client := srv.Client()
+	t.Cleanup(func() { _ = client.Close() })
+	if _, err := client.Initialize(context.Background()); err != nil {
+		t.Fatalf("initialize: %v", err)
+	}
+	return client
+}
+
+func TestSearchAndGet(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer()
+	srv.Now = func() time.Time { return time.Unix(1700000000, 0) }
+	title := "Storage"
+	srv.Seed(
+		vcontext.ContextItem{Content: "We chose SQLite", Title: &title},
+		vcontext.ContextItem{Content: "FTS5 for search", Importance: 5},
+	)
+	client := newClient(t, srv)
+
+	saved, err := client.SaveContext(ctx, vcontext.SaveContextParams{Content: "sqlite needs WAL mode"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID != "item-3" || saved.CreatedAt != 1700000000 {
+		t.Fatalf("save = %+v, want item-3 at the fixed time", saved)
+	}
+
+	for _, tt := range []struct {
+		query string
+		want  []string
+	}{
+		{"SQLITE", []string{"item-1", "item-3"}},
+		{"storage", []string{"item-1"}},
+		{"search", []string{"item-2"}},
+		{"postgres", nil},
+	} {
+		result, err := client.SearchContext(ctx, vcontext.SearchContextParams{Query: tt.query})
+		if err != nil {
+			t.Fatalf("search %q: %v", tt.query, err)
+		}
+		var got []string
+		for _, item := range result.Items {
+			got = append(got, item.ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Fatalf("search %q = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	item, err := client.GetContext(ctx, vcontext.GetContextParams{ID: "item-3"})
+	if err != nil || item.Content != "sqlite needs WAL mode" {
+		t.Fatalf("get item-3 = %+v, %v", item, err)
+	}
+	if _, err := client.GetContext(ctx, vcontext.GetContextParams{ID: "item-9"}); !errors.Is(err, vcontext.ErrNotFound) {
+		t.Fatalf("get item-9 = %v, want %v", err, vcontext.ErrNotFound)
+	}
+}
+
+func TestInjectedErrors(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer()
+	srv.Seed(vcontext.ContextItem{Content: "note"})
+	client := newClient(t, srv)
+	unavailable := &vcontext.RPCError{Code: vcontext.CodeStorage, Message: "disk full"}
+
+	srv.SetError(MethodSearchContext, unavailable)
+	for i := 0; i < 2; i++ {
+		_, err := client.SearchContext(ctx, vcontext.SearchContextParams{Query: "note"})
+		var rpcErr *vcontext.RPCError
+		if !errors.As(err, &rpcErr) || rpcErr.Code != vcontext.CodeStorage {
+			t.Fatalf("search %d = %v, want the injected error", i+1, err)
+		}
+	}
+	srv.SetError(MethodSearchContext, nil)
+	if _, err := client.SearchContext(ctx, vcontext.SearchContextParams{Query: "note"}); err != nil {
+		t.Fatalf("search after clearing the error: %v", err)
+	}
+
+	srv.FailNext(MethodGetContext, unavailable)
+	srv.FailNext(MethodGetContext, &vcontext.RPCError{Code: vcontext.CodeInvalidParams, Message: "bad id"})
+	if _, err := client.GetContext(ctx, vcontext.GetContextParams{ID: "item-1"}); err == nil || err.Error() != "disk full" {
+		t.Fatalf("first get = %v, want disk full", err)
+	}
+	if _, err := client.GetContext(ctx, vcontext.GetContextParams{ID: "item-1"}); !errors.Is(err, vcontext.ErrInvalidParams) {
+		t.Fatalf("second get = %v, want %v", err, vcontext.ErrInvalidParams)
+	}
+	if _, err := client.GetContext(ctx, vcontext.GetContextParams{ID: "item-1"}); err != nil {
+		t.Fatalf("third get: %v", err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	srv := NewServer()
+	client := newClient(t, srv)
+
+	srv.SetLatency(MethodReindex, 50*time.Millisecond)
+	start := time.Now()
+	if _, err := client.Reindex(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Fatalf("reindex answered after %v, want the 50ms latency", elapsed)
+	}
+
+	// A request given up by the client is cancelled on the server too.
+	srv.SetLatency(MethodReindex, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Reindex(ctx); !errors.Is(err, vcontext.ErrTimeout) {
+		t.Fatalf("slow reindex = %v, want %v", err, vcontext.ErrTimeout)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(srv.CallsTo(MethodCancelled)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the server never saw the cancellation")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	ctx := context.Background()
+	srv := NewServer()
+	client := newClient(t, srv)
+
+	if _, err := client.SaveContext(ctx, vcontext.SaveContextParams{Content: "note"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SearchContext(ctx, vcontext.SearchContextParams{Query: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SearchContext(ctx, vcontext.SearchContextParams{Query: "second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var methods []string
+	for _, call := range srv.Calls() {
+		methods = append(methods, call.Method)
+	}
+	want := []string{MethodInitialize, "notifications/initialized", MethodSaveContext, MethodSearchContext, MethodSearchContext}
+	if len(methods) != len(want) {
+		t.Fatalf("calls = %v, want %v", methods, want)
+	}
+	for i := range want {
+		if methods[i] != want[i] {
+			t.Fatalf("calls = %v, want %v", methods, want)
+		}
+	}
+
+	searches := srv.CallsTo(MethodSearchContext)
+	if len(searches) != 2 {
+		t.Fatalf("%d searches recorded, want 2", len(searches))
+	}
+	var params vcontext.SearchContextParams
+	if err := json.Unmarshal(searches[1].Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if params.Query != "second" || len(searches[1].ID) == 0 {
+		t.Fatalf("second search = %+v", searches[1])
+	}
+
+	srv.Reset()
+	if calls := srv.Calls(); len(calls) != 0 {
+		t.Fatalf("calls after reset = %v", calls)
+	}
+}